@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 
 	"github.com/aditip149209/okube/pkg/cli"
+	"github.com/aditip149209/okube/pkg/manager"
 	"github.com/aditip149209/okube/pkg/manifest"
 	"github.com/aditip149209/okube/pkg/task"
 	"github.com/google/uuid"
@@ -108,7 +112,10 @@ var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show cluster and task status.",
 	Long: `Display the status of all tasks in the cluster and the
-manager node that served the request. Works against any manager endpoint.`,
+manager node that served the request. Works against any manager endpoint.
+
+ATTEMPTS counts the scheduler's placement decisions for a task. SCHEDULING
+shows why the last attempt failed, or the node it chose and its score.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		client := cli.NewClient(managerEndpoints())
 
@@ -155,14 +162,18 @@ manager node that served the request. Works against any manager endpoint.`,
 
 		fmt.Println("=== Tasks ===")
 		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-		fmt.Fprintln(tw, "ID\tNAME\tSTATE\tIMAGE\tCONTAINER")
+		fmt.Fprintln(tw, "ID\tNAME\tSTATE\tIMAGE\tCONTAINER\tATTEMPTS\tSCHEDULING")
 		for _, t := range tasks {
 			containerID := t.ContainerID
 			if len(containerID) > 12 {
 				containerID = containerID[:12]
 			}
-			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
-				t.ID, t.Name, t.State, t.Image, containerID)
+			attempts := "-"
+			if t.Scheduling != nil {
+				attempts = strconv.Itoa(t.Scheduling.Attempts)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				t.ID, t.Name, t.State, t.Image, containerID, attempts, schedulingSummary(t.Scheduling))
 		}
 		tw.Flush()
 	},
@@ -172,7 +183,11 @@ var nodesCmd = &cobra.Command{
 	Use:   "nodes",
 	Short: "List worker nodes in the cluster.",
 	Long: `Show all registered worker nodes and their heartbeat timestamps.
-This data is read from the shared store via the manager API.`,
+This data is read from the shared store via the manager API.
+
+PARTITION is the connected component of mutually reachable workers the
+topology updater last placed the node in; 0 is the largest. Services are
+never scheduled into a different partition from their dependencies.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		client := cli.NewClient(managerEndpoints())
 		resp, err := client.Do(http.MethodGet, "/nodes", nil)
@@ -188,9 +203,20 @@ This data is read from the shared store via the manager API.`,
 		}
 
 		type nodeInfo struct {
-			ID        string `json:"id"`
-			Address   string `json:"address"`
-			Heartbeat string `json:"heartbeat"`
+			ID            string            `json:"id"`
+			Address       string            `json:"address"`
+			Heartbeat     string            `json:"heartbeat"`
+			Draining      bool              `json:"draining"`
+			Labels        map[string]string `json:"labels"`
+			Taints        []task.Taint      `json:"taints"`
+			Arch          string            `json:"arch"`
+			OS            string            `json:"os"`
+			DockerVersion string            `json:"dockerVersion"`
+			Capacity      struct {
+				Cores    int    `json:"cores"`
+				MemoryKb uint64 `json:"memoryKb"`
+			} `json:"capacity"`
+			Partition *int `json:"partition"`
 		}
 		var nodes []nodeInfo
 		if err := cli.ReadJSON(resp, &nodes); err != nil {
@@ -203,14 +229,201 @@ This data is read from the shared store via the manager API.`,
 		}
 
 		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-		fmt.Fprintln(tw, "ID\tADDRESS\tHEARTBEAT")
+		fmt.Fprintln(tw, "ID\tADDRESS\tSTATUS\tPARTITION\tPLATFORM\tCPUS\tMEMORY\tDOCKER\tLABELS\tTAINTS\tHEARTBEAT")
 		for _, n := range nodes {
-			fmt.Fprintf(tw, "%s\t%s\t%s\n", n.ID, n.Address, n.Heartbeat)
+			status := "Ready"
+			if n.Draining {
+				status = "Draining"
+			}
+			platform := "-"
+			if n.OS != "" || n.Arch != "" {
+				platform = n.OS + "/" + n.Arch
+			}
+			docker := n.DockerVersion
+			if docker == "" {
+				docker = "-"
+			}
+			partition := "-"
+			if n.Partition != nil {
+				partition = strconv.Itoa(*n.Partition)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+				n.ID, n.Address, status, partition, platform, n.Capacity.Cores,
+				formatMemoryKb(n.Capacity.MemoryKb), docker, formatLabels(n.Labels), formatTaints(n.Taints), n.Heartbeat)
 		}
 		tw.Flush()
 	},
 }
 
+var nodesTaintCmd = &cobra.Command{
+	Use:   "taint [node-id] [key=value:Effect | key[:Effect]-]...",
+	Short: "Add or remove taints on a worker node.",
+	Long: `Taint a worker so only tasks that tolerate the taint are placed on it.
+Effects are NoSchedule (no new tasks), PreferNoSchedule (avoided when
+possible) and NoExecute (no new tasks, and running tasks that do not
+tolerate it are evicted and rescheduled elsewhere).
+
+A trailing "-" removes a taint: "gpu:NoSchedule-" removes that taint,
+"gpu-" every taint with key gpu.
+
+  okube nodes taint workstation dedicated=heavy:NoSchedule
+  okube nodes taint workstation dedicated-`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		workerID := args[0]
+		var req manager.TaintRequest
+		for _, arg := range args[1:] {
+			if spec, ok := strings.CutSuffix(arg, "-"); ok {
+				key, effect, _ := strings.Cut(spec, ":")
+				key, _, _ = strings.Cut(key, "=")
+				req.Remove = append(req.Remove, task.Taint{Key: key, Effect: effect})
+				continue
+			}
+			t, err := task.ParseTaint(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			req.Add = append(req.Add, t)
+		}
+
+		client := cli.NewClient(managerEndpoints())
+		resp, err := client.Do(http.MethodPost, "/workers/"+url.PathEscape(workerID)+"/taints", req)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to taint node %s (HTTP %d): %s\n", workerID, resp.StatusCode, body)
+			os.Exit(1)
+		}
+
+		var result manager.TaintResult
+		if err := cli.ReadJSON(resp, &result); err != nil {
+			log.Fatalf("Error decoding taint result: %v", err)
+		}
+
+		fmt.Printf("Node %s taints: %s\n", result.WorkerID, formatTaints(result.Taints))
+		for _, id := range result.Evicted {
+			fmt.Printf("Evicted task %s\n", id)
+		}
+	},
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Display resource usage.",
+}
+
+var topNodesCmd = &cobra.Command{
+	Use:   "nodes",
+	Short: "Show current CPU, memory, network and disk usage of worker nodes.",
+	Long: `Show the most recent stats sample from each live worker. CPU usage is
+measured over the worker's last sampling interval, not since boot.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := cli.NewClient(managerEndpoints())
+		resp, err := client.Do(http.MethodGet, "/nodes/stats", nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching node stats: %v\n", err)
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to fetch node stats (HTTP %d): %s\n", resp.StatusCode, body)
+			os.Exit(1)
+		}
+
+		var nodes []manager.NodeStats
+		if err := cli.ReadJSON(resp, &nodes); err != nil {
+			log.Fatalf("Error decoding node stats: %v", err)
+		}
+
+		if len(nodes) == 0 {
+			fmt.Println("No live worker nodes.")
+			return
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NODE\tCPU%\tMAX CORE%\tMEM%\tLOAD1\tNET RX/s\tNET TX/s\tDISK R/s\tDISK W/s\tTASKS")
+		for _, n := range nodes {
+			if n.Sample == nil {
+				reason := n.Error
+				if reason == "" {
+					reason = "no samples yet"
+				}
+				fmt.Fprintf(tw, "%s\t<unavailable: %s>\n", n.ID, reason)
+				continue
+			}
+			sample := n.Sample
+			maxCore := 0.0
+			for _, c := range sample.PerCoreCpu {
+				if c > maxCore {
+					maxCore = c
+				}
+			}
+			var rx, tx, rd, wr float64
+			for _, iface := range sample.Network {
+				rx += iface.RxBytesPerSec
+				tx += iface.TxBytesPerSec
+			}
+			for _, d := range sample.DiskIO {
+				rd += d.ReadBytesPerSec
+				wr += d.WriteBytesPerSec
+			}
+			fmt.Fprintf(tw, "%s\t%.1f\t%.1f\t%.1f\t%.2f\t%s\t%s\t%s\t%s\t%d\n",
+				n.ID, sample.CpuPercent*100, maxCore*100, sample.MemUsedPercent, sample.Load1,
+				formatBytes(rx), formatBytes(tx), formatBytes(rd), formatBytes(wr), sample.TaskCount)
+		}
+		tw.Flush()
+	},
+}
+
+// formatBytes renders a byte count with a binary unit suffix.
+func formatBytes(b float64) string {
+	units := []string{"B", "Ki", "Mi", "Gi"}
+	i := 0
+	for b >= 1024 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%s", b, units[i])
+}
+
+// formatLabels renders labels as sorted key=value pairs, or "-" when empty.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func formatTaints(taints []task.Taint) string {
+	if len(taints) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(taints))
+	for _, t := range taints {
+		parts = append(parts, t.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatMemoryKb renders a kilobyte count in GiB, or "-" when unknown.
+func formatMemoryKb(kb uint64) string {
+	if kb == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fGi", float64(kb)/(1024*1024))
+}
+
 // managerEndpoints returns the list of manager addresses from the persistent
 // flag. It never returns an empty slice—defaults to localhost:5556.
 func managerEndpoints() []string {
@@ -273,16 +486,17 @@ started in dependency order with automatic service discovery via env vars.`,
 
 		if resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusOK {
 			var result struct {
-				App      string `json:"app"`
-				Status   string `json:"status"`
-				Services map[string]struct {
+				App         string  `json:"app"`
+				Status      string  `json:"status"`
+				NetworkCost float64 `json:"network_cost"`
+				Services    map[string]struct {
 					TaskID   string `json:"task_id"`
 					WorkerID string `json:"worker_id"`
 					Address  string `json:"address"`
 				} `json:"services"`
 			}
 			if json.Unmarshal([]byte(body), &result) == nil {
-				fmt.Printf("\nApplication %q deployed — status: %s\n\n", result.App, result.Status)
+				fmt.Printf("\nApplication %q deployed — status: %s, network cost: %.2f\n\n", result.App, result.Status, result.NetworkCost)
 				tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
 				fmt.Fprintln(tw, "SERVICE\tADDRESS\tTASK ID\tWORKER")
 				for name, info := range result.Services {
@@ -337,6 +551,59 @@ var appsCmd = &cobra.Command{
 	},
 }
 
+var reservationsCmd = &cobra.Command{
+	Use:   "reservations",
+	Short: "List bandwidth reservations.",
+	Long: `Show the bandwidth ledger: the bandwidth each placed task holds on the
+link to each of its dependencies, and the total reserved per link.
+Reservations are released when a task completes, fails or is rescheduled.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "/reservations"
+		if app, _ := cmd.Flags().GetString("app"); app != "" {
+			path += "?app=" + url.QueryEscape(app)
+		}
+
+		client := cli.NewClient(managerEndpoints())
+		resp, err := client.Do(http.MethodGet, path, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching reservations: %v\n", err)
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to list reservations (HTTP %d): %s\n", resp.StatusCode, body)
+			os.Exit(1)
+		}
+
+		var view manager.ReservationsView
+		if err := cli.ReadJSON(resp, &view); err != nil {
+			log.Fatalf("Error decoding reservations: %v", err)
+		}
+
+		if len(view.Reservations) == 0 {
+			fmt.Println("No bandwidth reserved.")
+			return
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TASK\tAPP\tEDGE\tLINK\tMBPS")
+		for _, r := range view.Reservations {
+			fmt.Fprintf(tw, "%s\t%s\t%s -> %s\t%s -> %s\t%.1f\n",
+				r.TaskID, r.AppID, r.From, r.To, r.FromNode, r.ToNode, r.Mbps)
+		}
+		tw.Flush()
+
+		fmt.Println()
+		tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "LINK\tTASKS\tRESERVED MBPS")
+		for _, l := range view.Links {
+			fmt.Fprintf(tw, "%s -> %s\t%d\t%.1f\n", l.From, l.To, l.Tasks, l.ReservedMbps)
+		}
+		tw.Flush()
+	},
+}
+
 var deleteAppCmd = &cobra.Command{
 	Use:   "delete [app-name]",
 	Short: "Delete (teardown) a deployed application.",
@@ -366,10 +633,15 @@ func init() {
 	rootCmd.AddCommand(stopCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(nodesCmd)
+	nodesCmd.AddCommand(nodesTaintCmd)
+	rootCmd.AddCommand(topCmd)
+	topCmd.AddCommand(topNodesCmd)
 	rootCmd.AddCommand(deployCmd)
 	rootCmd.AddCommand(appsCmd)
 	rootCmd.AddCommand(deleteAppCmd)
+	rootCmd.AddCommand(reservationsCmd)
 
 	runCmd.Flags().StringP("filename", "f", "", "Path to a JSON task definition file")
 	deployCmd.Flags().StringP("filename", "f", "", "Path to a YAML manifest file")
+	reservationsCmd.Flags().String("app", "", "Only show reservations held by this app")
 }
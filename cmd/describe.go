@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/cli"
+	"github.com/aditip149209/okube/pkg/manager"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/spf13/cobra"
+)
+
+var describeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Show details of a cluster object.",
+}
+
+var describeTaskCmd = &cobra.Command{
+	Use:   "task [task-id]",
+	Short: "Show a task's details and scheduling history.",
+	Long: `Show a task's spec, state and assigned worker, together with its
+scheduling status: how many placement attempts the scheduler has made, when
+the last one ran, and either the node it chose with its score or the reason
+no node could be chosen.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := cli.NewClient(managerEndpoints())
+		resp, err := client.Do(http.MethodGet, "/tasks/"+args[0], nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to get task (HTTP %d): %s\n", resp.StatusCode, body)
+			os.Exit(1)
+		}
+
+		var view manager.TaskView
+		if err := cli.ReadJSON(resp, &view); err != nil {
+			log.Fatalf("Error decoding task: %v", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "ID:\t%s\n", view.ID)
+		fmt.Fprintf(tw, "Name:\t%s\n", view.Name)
+		if view.AppID != "" {
+			fmt.Fprintf(tw, "App:\t%s\n", view.AppID)
+			fmt.Fprintf(tw, "Service:\t%s\n", orDash(view.ServiceID))
+		}
+		fmt.Fprintf(tw, "State:\t%s\n", view.State)
+		fmt.Fprintf(tw, "Image:\t%s\n", view.Image)
+		fmt.Fprintf(tw, "Worker:\t%s\n", orDash(view.WorkerID))
+		fmt.Fprintf(tw, "Container:\t%s\n", orDash(view.ContainerID))
+		fmt.Fprintf(tw, "Resources:\tcpu=%d memory=%d disk=%d\n", view.Cpu, view.Memory, view.Disk)
+		if len(view.Command) > 0 {
+			fmt.Fprintf(tw, "Command:\t%s\n", strings.Join(view.Command, " "))
+		}
+		fmt.Fprintf(tw, "Restarts:\t%d\n", view.RestartCount)
+		if !view.StartTime.IsZero() {
+			fmt.Fprintf(tw, "Started:\t%s\n", view.StartTime.Format(time.RFC3339))
+		}
+		if !view.EndTime.IsZero() {
+			fmt.Fprintf(tw, "Finished:\t%s\n", view.EndTime.Format(time.RFC3339))
+		}
+		tw.Flush()
+
+		fmt.Println()
+		fmt.Println("Scheduling:")
+		s := view.Scheduling
+		if s == nil {
+			fmt.Println("  No scheduling attempts recorded.")
+			return
+		}
+		tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "  Attempts:\t%d\n", s.Attempts)
+		fmt.Fprintf(tw, "  Last attempt:\t%s\n", s.LastAttempt.Format(time.RFC3339))
+		fmt.Fprintf(tw, "  Chosen node:\t%s\n", orDash(s.Node))
+		fmt.Fprintf(tw, "  Score:\t%s\n", orDash(formatOptionalFloat(s.Score, 3)))
+		fmt.Fprintf(tw, "  Last reason:\t%s\n", orDash(s.LastReason))
+		tw.Flush()
+	},
+}
+
+// schedulingSummary condenses a task's scheduling status into one cell: the
+// reason the last attempt failed, or the node it chose and its score.
+func schedulingSummary(s *task.SchedulingStatus) string {
+	switch {
+	case s == nil:
+		return "-"
+	case s.LastReason != "":
+		return s.LastReason
+	case s.Node != "" && s.Score != nil:
+		return fmt.Sprintf("placed on %s (score %s)", s.Node, formatOptionalFloat(s.Score, 3))
+	case s.Node != "":
+		return "placed on " + s.Node
+	default:
+		return "-"
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(describeCmd)
+	describeCmd.AddCommand(describeTaskCmd)
+}
@@ -7,7 +7,9 @@ import (
 	"time"
 
 	"github.com/aditip149209/okube/pkg/manager"
+	"github.com/aditip149209/okube/pkg/scheduler"
 	"github.com/aditip149209/okube/pkg/store"
+	"github.com/aditip149209/okube/pkg/topology"
 	"github.com/spf13/cobra"
 )
 
@@ -28,6 +30,42 @@ for high availability.`,
 		topologyProbeMode, _ := cmd.Flags().GetString("topology-probe-mode")
 		topologyProbeInterval, _ := cmd.Flags().GetDuration("topology-probe-interval")
 		topologyProbeSampleSize, _ := cmd.Flags().GetInt("topology-probe-sample-size")
+		topologyProbeSamples, _ := cmd.Flags().GetInt("topology-probe-samples")
+		topologyProbeTransport, _ := cmd.Flags().GetString("topology-probe-transport")
+		topologyBandwidthProbeInterval, _ := cmd.Flags().GetDuration("topology-bandwidth-probe-interval")
+		topologyBandwidthProbeBytes, _ := cmd.Flags().GetInt64("topology-bandwidth-probe-bytes")
+		topologyLatencyWindow, _ := cmd.Flags().GetInt("topology-latency-window")
+		topologyEWMAAlpha, _ := cmd.Flags().GetFloat64("topology-ewma-alpha")
+		filterLatencyStat, _ := cmd.Flags().GetString("filter-latency-stat")
+		scoreLatencyStat, _ := cmd.Flags().GetString("score-latency-stat")
+		topologyLatencyTTL, _ := cmd.Flags().GetDuration("topology-latency-ttl")
+		topologyBandwidthTTL, _ := cmd.Flags().GetDuration("topology-bandwidth-ttl")
+		topologyFailureThreshold, _ := cmd.Flags().GetInt("topology-failure-threshold")
+		inventoryRefreshInterval, _ := cmd.Flags().GetDuration("inventory-refresh-interval")
+		schedulerProfilesPath, _ := cmd.Flags().GetString("scheduler-profiles")
+		binpackWeightsFlag, _ := cmd.Flags().GetString("binpack-weights")
+
+		binpackWeights, err := scheduler.ParseBinpackWeights(binpackWeightsFlag)
+		if err != nil {
+			log.Fatalf("Invalid --binpack-weights: %v", err)
+		}
+		for flag, raw := range map[string]string{"filter-latency-stat": filterLatencyStat, "score-latency-stat": scoreLatencyStat} {
+			if topology.ParseLatencyStatistic(raw, "") == "" {
+				log.Fatalf("Invalid --%s %q: expected latest, ewma, p50 or p95", flag, raw)
+			}
+		}
+		if topology.ParseProbeTransport(topologyProbeTransport, "") == "" {
+			log.Fatalf("Invalid --topology-probe-transport %q: expected http, tcp or udp", topologyProbeTransport)
+		}
+
+		var schedulerProfiles []scheduler.Profile
+		if schedulerProfilesPath != "" {
+			profiles, err := scheduler.LoadProfiles(schedulerProfilesPath)
+			if err != nil {
+				log.Fatalf("Failed to load scheduler profiles: %v", err)
+			}
+			schedulerProfiles = profiles
+		}
 
 		endpoints := strings.Split(etcdEndpoints, ",")
 		etcdStore, err := store.NewEtcdStore(store.EtcdConfig{
@@ -50,19 +88,35 @@ for high availability.`,
 
 		advertiseAddr := fmt.Sprintf("%s:%d", host, port)
 		m := manager.NewWithConfig(manager.Config{
-			Workers:                 workerList,
-			SchedulerType:           schedulerType,
-			QueueSortStrategy:       queueSortStrategy,
-			TopologyProbeMode:       topologyProbeMode,
-			TopologyProbeInterval:   topologyProbeInterval,
-			TopologyProbeSampleSize: topologyProbeSampleSize,
-			Store:                   etcdStore,
-			ID:                      id,
-			AdvertiseAddr:           advertiseAddr,
+			Workers:                        workerList,
+			SchedulerType:                  schedulerType,
+			QueueSortStrategy:              queueSortStrategy,
+			SchedulerProfiles:              schedulerProfiles,
+			TopologyProbeMode:              topologyProbeMode,
+			TopologyProbeInterval:          topologyProbeInterval,
+			TopologyProbeSampleSize:        topologyProbeSampleSize,
+			TopologyProbeSamples:           topologyProbeSamples,
+			TopologyProbeTransport:         topologyProbeTransport,
+			TopologyBandwidthProbeInterval: topologyBandwidthProbeInterval,
+			TopologyBandwidthProbeBytes:    topologyBandwidthProbeBytes,
+			TopologyLatencyWindow:          topologyLatencyWindow,
+			TopologyEWMAAlpha:              topologyEWMAAlpha,
+			FilterLatencyStatistic:         filterLatencyStat,
+			ScoreLatencyStatistic:          scoreLatencyStat,
+			BinpackWeights:                 binpackWeights,
+			TopologyLatencyTTL:             topologyLatencyTTL,
+			TopologyBandwidthTTL:           topologyBandwidthTTL,
+			TopologyFailureThreshold:       topologyFailureThreshold,
+			InventoryRefreshInterval:       inventoryRefreshInterval,
+			Store:                          etcdStore,
+			ID:                             id,
+			AdvertiseAddr:                  advertiseAddr,
 		})
 
 		go m.UpdateTasks()
 		go m.DoHealthChecks()
+		go m.ReconcileReservations()
+		go m.RefreshInventory()
 
 		mapi := manager.Api{Address: host, Port: port, Manager: m}
 		log.Printf("Starting manager %s on http://%s", m.ID, advertiseAddr)
@@ -74,11 +128,25 @@ func init() {
 	rootCmd.AddCommand(managerCmd)
 	managerCmd.Flags().StringP("host", "H", "0.0.0.0", "Hostname or IP address to bind to")
 	managerCmd.Flags().IntP("port", "p", 5556, "Port on which to listen")
-	managerCmd.Flags().String("scheduler", "roundrobin", "Scheduler type (roundrobin or epvm)")
+	managerCmd.Flags().String("scheduler", "roundrobin", "Scheduler type (roundrobin, epvm or binpack)")
+	managerCmd.Flags().String("binpack-weights", "memory=1,cpu=1,disk=1", "Per-resource weights of the binpack scheduler's fullness score")
+	managerCmd.Flags().String("scheduler-profiles", "", "YAML file of scheduler profiles that tasks can select with schedulerProfile")
 	managerCmd.Flags().String("queue-sort", "kahn", "Queue sort strategy (kahn, reversekahn, alternatekahn)")
 	managerCmd.Flags().String("topology-probe-mode", "full-mesh", "Topology probe mode (full-mesh or sampled)")
 	managerCmd.Flags().Duration("topology-probe-interval", 30*time.Second, "Interval between topology probe updates")
 	managerCmd.Flags().Int("topology-probe-sample-size", 2, "Per-node sample count when topology probe mode is sampled")
+	managerCmd.Flags().Int("topology-probe-samples", 5, "RTT samples a worker takes per latency probe (the median is recorded)")
+	managerCmd.Flags().String("topology-probe-transport", "http", "How workers measure RTT to peers: http (GET /probe/ping), tcp (connect time) or udp (echo)")
+	managerCmd.Flags().Duration("topology-bandwidth-probe-interval", 10*time.Minute, "Interval between worker-to-worker bandwidth probes")
+	managerCmd.Flags().Int64("topology-bandwidth-probe-bytes", 8<<20, "Payload size in bytes for each bandwidth probe")
+	managerCmd.Flags().Int("topology-latency-window", 20, "Latency probe outcomes kept per link for percentiles, jitter and loss")
+	managerCmd.Flags().Float64("topology-ewma-alpha", 0.3, "Weight of the newest latency sample in the per-link EWMA (0-1]")
+	managerCmd.Flags().String("filter-latency-stat", "p50", "Latency statistic the network filter checks against maxNetworkCost (latest, ewma, p50, p95)")
+	managerCmd.Flags().String("score-latency-stat", "ewma", "Latency statistic the network score uses (latest, ewma, p50, p95)")
+	managerCmd.Flags().Duration("topology-latency-ttl", 5*time.Minute, "Age after which a latency measurement is treated as unknown by the scheduler (0 disables)")
+	managerCmd.Flags().Duration("topology-bandwidth-ttl", 30*time.Minute, "Age after which a bandwidth measurement is treated as unknown by the scheduler (0 disables)")
+	managerCmd.Flags().Int("topology-failure-threshold", 3, "Consecutive lost probes after which a link is considered down when detecting network partitions")
+	managerCmd.Flags().Duration("inventory-refresh-interval", 15*time.Second, "Interval between background refreshes of worker stats and allocations used by the scheduler")
 	managerCmd.Flags().String("etcd-endpoints", "localhost:2379", "Comma-separated etcd endpoints")
 	managerCmd.Flags().StringP("workers", "w", "", "Comma-separated initial worker addresses (host:port)")
 	managerCmd.Flags().String("id", "", "Manager ID (defaults to hostname or random UUID)")
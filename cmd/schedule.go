@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aditip149209/okube/pkg/cli"
+	"github.com/aditip149209/okube/pkg/scheduler"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Explain where the scheduler would place a task.",
+	Long: `Run the scheduler pipeline for a task definition without dispatching it.
+The task file has the same format as for "okube run".
+
+For every schedulable node the output shows each filter verdict with its
+reason, the raw resource score, the dependency network cost and its
+normalized score, and the combined score (lower is better). The node the
+scheduler would pick is marked with "*".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if !dryRun {
+			fmt.Fprintln(os.Stderr, "Error: only --dry-run is supported; use \"okube run\" to submit a task")
+			os.Exit(1)
+		}
+
+		filename, _ := cmd.Flags().GetString("filename")
+		if filename == "" {
+			fmt.Fprintln(os.Stderr, "Error: --filename is required")
+			os.Exit(1)
+		}
+		output, _ := cmd.Flags().GetString("output")
+
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+
+		var te task.TaskEvent
+		if err := json.Unmarshal(data, &te); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing task event JSON: %v\n", err)
+			os.Exit(1)
+		}
+		if te.Task.ID == uuid.Nil {
+			te.Task.ID = uuid.New()
+		}
+
+		client := cli.NewClient(managerEndpoints())
+		resp, err := client.Do(http.MethodPost, "/schedule/explain", te)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to explain placement (HTTP %d): %s\n", resp.StatusCode, body)
+			os.Exit(1)
+		}
+
+		var explanation scheduler.Explanation
+		if err := cli.ReadJSON(resp, &explanation); err != nil {
+			log.Fatalf("Error decoding explanation: %v", err)
+		}
+
+		switch strings.ToLower(output) {
+		case "", "table":
+			printExplanation(os.Stdout, &explanation)
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(explanation)
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown output format %q (expected table or json)\n", output)
+			os.Exit(1)
+		}
+	},
+}
+
+func printExplanation(w io.Writer, e *scheduler.Explanation) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tNODE\tFILTERS\tRESOURCE\tNET COST\tNET SCORE\tSCORE")
+	for _, n := range e.Nodes {
+		mark := ""
+		if n.Node == e.Pick {
+			mark = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			mark, n.Node, formatVerdicts(n.Filters),
+			orDash(formatOptionalFloat(n.ResourceScore, 3)),
+			orDash(formatOptionalFloat(n.NetworkCost, 2)),
+			orDash(formatOptionalFloat(n.NetworkScore, 1)),
+			orDash(formatOptionalFloat(n.Score, 3)))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	if e.Profile != "" {
+		fmt.Fprintf(w, "Profile: %s\n", e.Profile)
+	}
+	fmt.Fprintf(w, "Weights: network %.2f, resource %.2f\n", e.NetworkWeight, e.ResourceWeight)
+	if e.Pick != "" {
+		fmt.Fprintf(w, "Would place on: %s\n", e.Pick)
+	} else {
+		fmt.Fprintf(w, "No placement: %s\n", e.Reason)
+	}
+}
+
+// formatVerdicts renders "resource:ok network:ok", or the first rejection
+// with its reason.
+func formatVerdicts(verdicts []scheduler.FilterVerdict) string {
+	parts := make([]string, 0, len(verdicts))
+	for _, v := range verdicts {
+		if !v.Passed {
+			parts = append(parts, fmt.Sprintf("%s:rejected (%s)", v.Filter, v.Reason))
+			break
+		}
+		parts = append(parts, v.Filter+":ok")
+	}
+	return strings.Join(parts, " ")
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.Flags().Bool("dry-run", false, "Explain the placement without dispatching the task")
+	scheduleCmd.Flags().StringP("filename", "f", "", "Path to a JSON task definition file")
+	scheduleCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
+}
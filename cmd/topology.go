@@ -0,0 +1,423 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aditip149209/okube/pkg/cli"
+	"github.com/aditip149209/okube/pkg/manager"
+	"github.com/aditip149209/okube/pkg/topology"
+	"github.com/spf13/cobra"
+)
+
+var topologyCmd = &cobra.Command{
+	Use:   "topology",
+	Short: "Show the network topology the scheduler uses.",
+	Long: `Show measured latency and bandwidth between worker nodes together with
+each node's zone and region.
+
+Latency cells prefixed with "~" are estimates from network coordinates;
+cells suffixed with "*" are older than the manager's TTL and are ignored by
+the scheduler. Cells prefixed with "=" are pinned or capped by an
+administrator, and "X" marks a forbidden link. Use --output to export the
+data as csv, json or dot (Graphviz).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		client := cli.NewClient(managerEndpoints())
+		resp, err := client.Do(http.MethodGet, "/topology", nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching topology: %v\n", err)
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to fetch topology (HTTP %d): %s\n", resp.StatusCode, body)
+			os.Exit(1)
+		}
+
+		var view manager.TopologyView
+		if err := cli.ReadJSON(resp, &view); err != nil {
+			log.Fatalf("Error decoding topology: %v", err)
+		}
+
+		switch strings.ToLower(output) {
+		case "", "table":
+			printTopologyTable(os.Stdout, &view)
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(view)
+		case "csv":
+			if err := writeTopologyCSV(os.Stdout, &view); err != nil {
+				log.Fatalf("Error writing csv: %v", err)
+			}
+		case "dot":
+			writeTopologyDOT(os.Stdout, &view)
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown output format %q (expected table, csv, json or dot)\n", output)
+			os.Exit(1)
+		}
+	},
+}
+
+// topologyLinks indexes the view's links by from and to node.
+func topologyLinks(view *manager.TopologyView) map[string]map[string]manager.TopologyLinkView {
+	links := make(map[string]map[string]manager.TopologyLinkView)
+	for _, l := range view.Links {
+		if _, ok := links[l.From]; !ok {
+			links[l.From] = make(map[string]manager.TopologyLinkView)
+		}
+		links[l.From][l.To] = l
+	}
+	return links
+}
+
+func printTopologyTable(w io.Writer, view *manager.TopologyView) {
+	if len(view.Nodes) == 0 {
+		fmt.Fprintln(w, "No topology data.")
+		return
+	}
+
+	links := topologyLinks(view)
+	fmt.Fprintf(w, "Topology version %d\n\n", view.Version)
+
+	printMatrix := func(title string, cell func(l manager.TopologyLinkView) string) {
+		fmt.Fprintln(w, title)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		header := []string{"NODE", "ZONE", "REGION"}
+		for _, n := range view.Nodes {
+			header = append(header, n.ID)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, from := range view.Nodes {
+			row := []string{from.ID, orDash(from.Zone), orDash(from.Region)}
+			for _, to := range view.Nodes {
+				if from.ID == to.ID {
+					row = append(row, ".")
+					continue
+				}
+				l, ok := links[from.ID][to.ID]
+				if !ok {
+					row = append(row, "-")
+					continue
+				}
+				if l.Forbidden {
+					row = append(row, "X")
+					continue
+				}
+				row = append(row, cell(l))
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+
+	printMatrix("LATENCY (ms, EWMA)", func(l manager.TopologyLinkView) string {
+		if l.LatencyMs == nil {
+			return "-"
+		}
+		s := strconv.FormatFloat(*l.LatencyMs, 'f', 2, 64)
+		if l.LatencyEstimated {
+			s = "~" + s
+		}
+		if l.LatencyPinned {
+			s = "=" + s
+		}
+		if l.LatencyStale {
+			s += "*"
+		}
+		return s
+	})
+	printMatrix("BANDWIDTH (available/capacity Mbps)", func(l manager.TopologyLinkView) string {
+		if l.BandwidthMbps == nil && l.AvailableMbps == nil {
+			return "-"
+		}
+		s := orDash(formatOptionalFloat(l.AvailableMbps, 0)) + "/" + orDash(formatOptionalFloat(l.BandwidthMbps, 0))
+		if l.BandwidthCapped {
+			s = "=" + s
+		}
+		if l.BandwidthStale {
+			s += "*"
+		}
+		return s
+	})
+}
+
+func writeTopologyCSV(w io.Writer, view *manager.TopologyView) error {
+	zones := make(map[string]manager.TopologyNodeView, len(view.Nodes))
+	for _, n := range view.Nodes {
+		zones[n.ID] = n
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"from", "from_zone", "from_region", "to", "to_zone", "to_region",
+		"latency_ms", "latency_estimated", "latency_p50_ms", "latency_p95_ms", "jitter_ms", "loss_rate", "latency_stale",
+		"bandwidth_mbps", "available_mbps", "bandwidth_stale",
+		"latency_pinned", "bandwidth_capped", "forbidden",
+	})
+	for _, l := range view.Links {
+		from, to := zones[l.From], zones[l.To]
+		cw.Write([]string{
+			l.From, from.Zone, from.Region, l.To, to.Zone, to.Region,
+			formatOptionalFloat(l.LatencyMs, 3), strconv.FormatBool(l.LatencyEstimated),
+			formatOptionalFloat(l.LatencyP50Ms, 3), formatOptionalFloat(l.LatencyP95Ms, 3),
+			formatOptionalFloat(l.JitterMs, 3), formatOptionalFloat(l.LossRate, 3), strconv.FormatBool(l.LatencyStale),
+			formatOptionalFloat(l.BandwidthMbps, 1), formatOptionalFloat(l.AvailableMbps, 1), strconv.FormatBool(l.BandwidthStale),
+			strconv.FormatBool(l.LatencyPinned), strconv.FormatBool(l.BandwidthCapped), strconv.FormatBool(l.Forbidden),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeTopologyDOT renders the topology as a Graphviz digraph. Nodes are
+// grouped into one cluster per zone; estimated links are dashed, stale
+// links grey and forbidden links red.
+func writeTopologyDOT(w io.Writer, view *manager.TopologyView) {
+	fmt.Fprintln(w, "digraph topology {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+
+	byZone := make(map[string][]manager.TopologyNodeView)
+	zoneOrder := make([]string, 0)
+	for _, n := range view.Nodes {
+		if _, ok := byZone[n.Zone]; !ok {
+			zoneOrder = append(zoneOrder, n.Zone)
+		}
+		byZone[n.Zone] = append(byZone[n.Zone], n)
+	}
+
+	for i, zone := range zoneOrder {
+		indent := "  "
+		if zone != "" {
+			fmt.Fprintf(w, "  subgraph cluster_%d {\n", i)
+			fmt.Fprintf(w, "    label=%s;\n", strconv.Quote(zone))
+			indent = "    "
+		}
+		for _, n := range byZone[zone] {
+			label := n.ID
+			if n.Region != "" {
+				label += "\\n" + n.Region
+			}
+			style := ""
+			if !n.Live {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(w, "%s%s [label=\"%s\"%s];\n", indent, strconv.Quote(n.ID), label, style)
+		}
+		if zone != "" {
+			fmt.Fprintln(w, "  }")
+		}
+	}
+
+	for _, l := range view.Links {
+		parts := make([]string, 0, 2)
+		if l.LatencyMs != nil {
+			s := fmt.Sprintf("%.1fms", *l.LatencyMs)
+			if l.LatencyEstimated {
+				s = "~" + s
+			}
+			parts = append(parts, s)
+		}
+		if l.BandwidthMbps != nil {
+			parts = append(parts, fmt.Sprintf("%.0fMbps", *l.BandwidthMbps))
+		}
+		attrs := []string{fmt.Sprintf("label=%s", strconv.Quote(strings.Join(parts, " / ")))}
+		if l.LatencyEstimated {
+			attrs = append(attrs, "style=dashed")
+		}
+		switch {
+		case l.Forbidden:
+			attrs = append(attrs, "color=red", "style=dotted")
+		case l.LatencyStale || l.BandwidthStale:
+			attrs = append(attrs, "color=grey")
+		}
+		fmt.Fprintf(w, "  %s -> %s [%s];\n", strconv.Quote(l.From), strconv.Quote(l.To), strings.Join(attrs, ", "))
+	}
+
+	fmt.Fprintln(w, "}")
+}
+
+var topologySetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Declare zones, regions and link properties.",
+	Long: `Declare topology facts that probes cannot discover. They are stored
+separately from probed data and merged over it whenever the scheduler reads
+the topology.`,
+}
+
+var topologySetNodeCmd = &cobra.Command{
+	Use:   "node [node-id]",
+	Short: "Set the zone and region of a node.",
+	Long: `Set the zone and/or region of a node. A flag that is not given keeps the
+current declaration; pass an empty value (--zone "") to clear it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := cli.NewClient(managerEndpoints())
+		resp, err := client.Do(http.MethodGet, "/topology/overrides", nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching topology overrides: %v\n", err)
+			os.Exit(1)
+		}
+		// The current declaration is merged with the flags, so a failed read
+		// must not be taken for an empty one.
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to fetch topology overrides (HTTP %d): %s\n", resp.StatusCode, respBody)
+			os.Exit(1)
+		}
+		var overrides topology.Overrides
+		if err := cli.ReadJSON(resp, &overrides); err != nil {
+			log.Fatalf("Error decoding topology overrides: %v", err)
+		}
+
+		zone := overrides.Zones[args[0]]
+		region := overrides.Regions[args[0]]
+		if cmd.Flags().Changed("zone") {
+			zone, _ = cmd.Flags().GetString("zone")
+		}
+		if cmd.Flags().Changed("region") {
+			region, _ = cmd.Flags().GetString("region")
+		}
+
+		body := manager.SetNodePlacementRequest{Zone: zone, Region: region}
+		resp, err = client.Do(http.MethodPut, "/topology/overrides/nodes/"+url.PathEscape(args[0]), body)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating node placement: %v\n", err)
+			os.Exit(1)
+		}
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to update node placement (HTTP %d): %s\n", resp.StatusCode, respBody)
+			os.Exit(1)
+		}
+		resp.Body.Close()
+		fmt.Printf("Node %s: zone=%s region=%s\n", args[0], orDash(zone), orDash(region))
+	},
+}
+
+var topologySetLinkCmd = &cobra.Command{
+	Use:   "link [from-node] [to-node]",
+	Short: "Pin the cost, cap the bandwidth or forbid a link.",
+	Long: `Pin the latency (network cost) of a link, cap its bandwidth, or forbid
+it. Only the flags given are changed; other properties of an existing
+declaration are kept. Links are declared in both directions unless
+--one-way is set.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		from, to := args[0], args[1]
+		oneWay, _ := cmd.Flags().GetBool("one-way")
+
+		client := cli.NewClient(managerEndpoints())
+		resp, err := client.Do(http.MethodGet, "/topology/overrides", nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching topology overrides: %v\n", err)
+			os.Exit(1)
+		}
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to fetch topology overrides (HTTP %d): %s\n", resp.StatusCode, respBody)
+			os.Exit(1)
+		}
+		var overrides topology.Overrides
+		if err := cli.ReadJSON(resp, &overrides); err != nil {
+			log.Fatalf("Error decoding topology overrides: %v", err)
+		}
+
+		link, _ := overrides.GetLink(from, to)
+		if cmd.Flags().Changed("latency") {
+			v, _ := cmd.Flags().GetFloat64("latency")
+			link.LatencyMs = &v
+		}
+		if cmd.Flags().Changed("bandwidth-cap") {
+			v, _ := cmd.Flags().GetFloat64("bandwidth-cap")
+			link.BandwidthCapMbps = &v
+		}
+		if cmd.Flags().Changed("forbidden") {
+			link.Forbidden, _ = cmd.Flags().GetBool("forbidden")
+		}
+
+		path := fmt.Sprintf("/topology/overrides/links/%s/%s?symmetric=%t", url.PathEscape(from), url.PathEscape(to), !oneWay)
+		resp, err = client.Do(http.MethodPut, path, link)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating link: %v\n", err)
+			os.Exit(1)
+		}
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to update link (HTTP %d): %s\n", resp.StatusCode, respBody)
+			os.Exit(1)
+		}
+		resp.Body.Close()
+		fmt.Printf("Link %s -> %s updated\n", from, to)
+	},
+}
+
+var topologyUnsetLinkCmd = &cobra.Command{
+	Use:   "unset-link [from-node] [to-node]",
+	Short: "Remove administrator declarations for a link.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		oneWay, _ := cmd.Flags().GetBool("one-way")
+
+		client := cli.NewClient(managerEndpoints())
+		path := fmt.Sprintf("/topology/overrides/links/%s/%s?symmetric=%t", url.PathEscape(args[0]), url.PathEscape(args[1]), !oneWay)
+		resp, err := client.Do(http.MethodDelete, path, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing link declaration: %v\n", err)
+			os.Exit(1)
+		}
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := cli.ReadBody(resp)
+			fmt.Fprintf(os.Stderr, "Failed to remove link declaration (HTTP %d): %s\n", resp.StatusCode, respBody)
+			os.Exit(1)
+		}
+		resp.Body.Close()
+		fmt.Printf("Link %s -> %s declaration removed\n", args[0], args[1])
+	},
+}
+
+func formatOptionalFloat(v *float64, prec int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', prec, 64)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(topologyCmd)
+	topologyCmd.Flags().StringP("output", "o", "table", "Output format: table, csv, json or dot")
+
+	topologyCmd.AddCommand(topologySetCmd)
+	topologyCmd.AddCommand(topologyUnsetLinkCmd)
+	topologySetCmd.AddCommand(topologySetNodeCmd)
+	topologySetCmd.AddCommand(topologySetLinkCmd)
+
+	topologySetNodeCmd.Flags().String("zone", "", "Zone of the node (empty clears it)")
+	topologySetNodeCmd.Flags().String("region", "", "Region of the node (empty clears it)")
+	topologySetLinkCmd.Flags().Float64("latency", 0, "Pinned latency (network cost) in ms")
+	topologySetLinkCmd.Flags().Float64("bandwidth-cap", 0, "Maximum bandwidth in Mbps")
+	topologySetLinkCmd.Flags().Bool("forbidden", false, "Forbid placing dependent services across this link")
+	topologySetLinkCmd.Flags().Bool("one-way", false, "Only declare the from -> to direction")
+	topologyUnsetLinkCmd.Flags().Bool("one-way", false, "Only remove the from -> to direction")
+}
@@ -7,13 +7,14 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/aditip149209/okube/pkg/store"
-	"github.com/aditip149209/okube/pkg/task"
+	"github.com/aditip149209/okube/pkg/topology"
 	"github.com/aditip149209/okube/pkg/utils"
 	"github.com/aditip149209/okube/pkg/worker"
-	"github.com/golang-collections/collections/queue"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
@@ -31,6 +32,13 @@ var workerCmd = &cobra.Command{
 		managerHost, _ := cmd.Flags().GetString("manager-host")
 		managerPort, _ := cmd.Flags().GetInt("manager-port")
 		advertiseAddr, _ := cmd.Flags().GetString("advertise-address")
+		drainTimeout, _ := cmd.Flags().GetDuration("drain-timeout")
+		rawLabels, _ := cmd.Flags().GetStringSlice("label")
+
+		labels, err := worker.ParseLabels(rawLabels)
+		if err != nil {
+			log.Fatalf("Invalid --label: %v", err)
+		}
 
 		workerID := name
 		if workerID == "" {
@@ -50,26 +58,79 @@ var workerCmd = &cobra.Command{
 		}
 
 		workerAddress := fmt.Sprintf("%s:%d", registerIP, port)
-		managerAddress := fmt.Sprintf("%s:%d", managerHost, managerPort)
-		log.Println("Starting worker.")
-		w := worker.Worker{
-			Name:  workerID,
-			Queue: *queue.New(),
-			Db:    make(map[uuid.UUID]*task.Task),
+
+		// Prefer the cluster-wide --manager list so heartbeats survive leader
+		// failover; fall back to the single --manager-host/--manager-port.
+		endpoints := []string{fmt.Sprintf("%s:%d", managerHost, managerPort)}
+		if raw, _ := rootCmd.PersistentFlags().GetString("manager"); raw != "" {
+			endpoints = managerEndpoints()
 		}
+		mc := worker.NewManagerClient(endpoints)
+		log.Println("Starting worker.")
+		w := worker.New(workerID)
 
 		ctx := context.Background()
-		if err := worker.RegisterWithManager(ctx, managerAddress, store.Worker{ID: workerID, Address: workerAddress}); err != nil {
+		discoverCtx, discoverCancel := context.WithTimeout(ctx, 10*time.Second)
+		if leader, err := mc.DiscoverLeader(discoverCtx); err != nil {
+			log.Printf("Warning: could not discover manager leader: %v", err)
+		} else {
+			log.Printf("Discovered manager leader at %s", leader)
+		}
+		discoverCancel()
+
+		meta := store.Worker{ID: workerID, Address: workerAddress, Labels: labels}
+		worker.DescribeHost(&meta)
+		if err := mc.Register(ctx, meta); err != nil {
 			log.Fatalf("Failed to register worker %s: %v", workerID, err)
 		}
-		go worker.StartHeartbeat(ctx, managerAddress, workerID, 10*time.Second)
+		heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+		go worker.StartHeartbeat(heartbeatCtx, mc, meta, 10*time.Second, w.IsDraining)
 
-		api := worker.Api{Address: host, Port: port, Worker: &w}
+		api := worker.Api{Address: host, Port: port, Worker: w, Peers: worker.NewPeerSet(mc)}
 		go w.RunTasks()
 		go w.CollectStats()
 		go w.UpdateTasks()
 		log.Printf("Starting worker API on http://%s:%d", host, port)
-		api.Start()
+		go api.Start()
+
+		// Peers probing with --topology-probe-transport=udp expect the echo
+		// responder on the same port number as the API.
+		echoCtx, stopEcho := context.WithCancel(ctx)
+		defer stopEcho()
+		go func() {
+			if err := topology.ServeUDPEcho(echoCtx, fmt.Sprintf("%s:%d", host, port)); err != nil {
+				log.Printf("Warning: UDP echo responder stopped: %v", err)
+			}
+		}()
+
+		sigCtx, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		<-sigCtx.Done()
+		stopSignals()
+
+		log.Printf("Shutdown signal received; draining worker %s (timeout %s)", workerID, drainTimeout)
+		drainCtx, cancel := context.WithTimeout(ctx, drainTimeout)
+		defer cancel()
+
+		if err := mc.NotifyDraining(drainCtx, workerID); err != nil {
+			log.Printf("Warning: could not notify manager that worker %s is draining: %v", workerID, err)
+		}
+		if err := w.Drain(drainCtx); err != nil {
+			log.Printf("Warning: drain of worker %s incomplete: %v", workerID, err)
+		}
+
+		stopHeartbeat()
+		deregisterCtx, deregisterCancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := mc.Deregister(deregisterCtx, workerID); err != nil {
+			log.Printf("Warning: could not deregister worker %s: %v", workerID, err)
+		}
+		deregisterCancel()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := api.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: worker API shutdown: %v", err)
+		}
+		shutdownCancel()
+		log.Printf("Worker %s stopped", workerID)
 	},
 }
 
@@ -78,8 +139,10 @@ func init() {
 	workerCmd.Flags().StringP("host", "H", "0.0.0.0", "Hostname or IP address")
 	workerCmd.Flags().IntP("port", "p", 5556, "Port on which to listen")
 	workerCmd.Flags().StringP("name", "n", fmt.Sprintf("worker-%s", uuid.New().String()), "Name of the worker")
-	workerCmd.Flags().String("manager-host", "localhost", "Manager host to register with")
-	workerCmd.Flags().Int("manager-port", 5556, "Manager port to register with")
+	workerCmd.Flags().String("manager-host", "localhost", "Manager host to register with (ignored when --manager is set)")
+	workerCmd.Flags().Int("manager-port", 5556, "Manager port to register with (ignored when --manager is set)")
 	workerCmd.Flags().String("advertise-address", "", "IP address to advertise to the manager (auto-detected if empty)")
+	workerCmd.Flags().StringSlice("label", nil, "Node labels as key=value pairs, e.g. --label zone=kitchen,disk=ssd")
+	workerCmd.Flags().Duration("drain-timeout", 30*time.Second, "Maximum time to spend draining tasks on SIGTERM/SIGINT before exiting")
 	workerCmd.Flags().StringP("dbtype", "d", "memory", "Type of datastore to use for tasks (\"memory\" or \"persistent\")")
 }
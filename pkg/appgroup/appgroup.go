@@ -12,6 +12,16 @@ type DependencyEdge struct {
 	To             string   `json:"to"`                       // target service ID (the dependency)
 	MinBandwidth   *float64 `json:"minBandwidth,omitempty"`   // optional minimum bandwidth requirement in Mbps
 	MaxNetworkCost *float64 `json:"maxNetworkCost,omitempty"` // optional maximum network cost (abstract unit)
+	Soft           bool     `json:"soft,omitempty"`           // constraints only lower the score instead of excluding nodes
+	Weight         float64  `json:"weight,omitempty"`         // how much the edge's network cost counts in app placement; 0 means 1
+}
+
+// EffectiveWeight returns the edge's weight, defaulting to 1.
+func (e DependencyEdge) EffectiveWeight() float64 {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
 }
 
 // AppGroup represents an application composed of multiple services (tasks)
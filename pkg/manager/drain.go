@@ -0,0 +1,144 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/store"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/go-chi/chi"
+)
+
+// DrainResult lists the tasks that were handed back to the scheduler when a
+// worker started draining.
+type DrainResult struct {
+	WorkerID    string   `json:"worker_id"`
+	Rescheduled []string `json:"rescheduled"`
+}
+
+// DrainWorker marks a worker as draining so it receives no new placements and
+// resets every task currently assigned to it to Pending. The pending-task
+// watch then places those tasks on the remaining workers.
+func (m *Manager) DrainWorker(ctx context.Context, workerID string) (*DrainResult, error) {
+	if !m.IsLeader() {
+		return nil, ErrNotLeader
+	}
+	if m.Store == nil {
+		return nil, errors.New("store not configured")
+	}
+
+	if err := m.Store.SetWorkerDraining(ctx, workerID, true); err != nil {
+		return nil, err
+	}
+
+	records, err := m.Store.ListTasks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing tasks for drain: %w", err)
+	}
+
+	result := &DrainResult{WorkerID: workerID, Rescheduled: []string{}}
+	for _, rec := range records {
+		if rec.Task == nil || rec.WorkerID != workerID {
+			continue
+		}
+		if rec.Task.State != task.Running && rec.Task.State != task.Scheduled {
+			continue
+		}
+
+		m.resetTaskToPending(*rec.Task)
+		result.Rescheduled = append(result.Rescheduled, rec.Task.ID.String())
+	}
+
+	log.Printf("Manager %s: worker %s draining; %d task(s) queued for rescheduling", m.ID, workerID, len(result.Rescheduled))
+	return result, nil
+}
+
+// DeregisterWorker removes a worker from the store once it has finished
+// draining.
+func (m *Manager) DeregisterWorker(ctx context.Context, workerID string) error {
+	if !m.IsLeader() {
+		return ErrNotLeader
+	}
+	if m.Store == nil {
+		return errors.New("store not configured")
+	}
+
+	if err := m.Store.DeregisterWorker(ctx, workerID); err != nil {
+		return err
+	}
+
+	log.Printf("Manager %s: worker %s deregistered", m.ID, workerID)
+	return nil
+}
+
+// DrainWorkerHandler handles POST /workers/{workerID}/drain.
+func (a *Api) DrainWorkerHandler(w http.ResponseWriter, r *http.Request) {
+	if a.forwardToLeader(w, r) {
+		return
+	}
+
+	workerID := chi.URLParam(r, "workerID")
+	if workerID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: "worker id is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	result, err := a.Manager.DrainWorker(ctx, workerID)
+	if err != nil {
+		writeWorkerLifecycleError(w, workerID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// DeregisterWorkerHandler handles DELETE /workers/{workerID}.
+func (a *Api) DeregisterWorkerHandler(w http.ResponseWriter, r *http.Request) {
+	if a.forwardToLeader(w, r) {
+		return
+	}
+
+	workerID := chi.URLParam(r, "workerID")
+	if workerID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: "worker id is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := a.Manager.DeregisterWorker(ctx, workerID); err != nil {
+		writeWorkerLifecycleError(w, workerID, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeWorkerLifecycleError(w http.ResponseWriter, workerID string, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusNotFound, Message: "worker not registered"})
+	case errors.Is(err, ErrNotLeader):
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusServiceUnavailable, Message: "not leader"})
+	default:
+		msg := fmt.Sprintf("Error updating worker %s: %v", workerID, err)
+		log.Print(msg)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusInternalServerError, Message: msg})
+	}
+}
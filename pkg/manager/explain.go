@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/scheduler"
+	"github.com/aditip149209/okube/pkg/task"
+)
+
+// ExplainPlacement runs the scheduler over the current cluster state for t
+// without reserving, assigning or dispatching anything.
+func (m *Manager) ExplainPlacement(ctx context.Context, t task.Task) (*scheduler.Explanation, error) {
+	if m.Store == nil {
+		return nil, errors.New("store not configured")
+	}
+	sched, err := m.schedulerFor(t)
+	if err != nil {
+		return nil, err
+	}
+	explainer, ok := sched.(scheduler.Explainer)
+	if !ok {
+		return nil, fmt.Errorf("scheduler %T cannot explain placements", sched)
+	}
+
+	in, err := m.schedulingInputs(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	return explainer.Explain(t, in.nodes, in.scoreCtx.Filter, in.scoreCtx), nil
+}
+
+// ExplainScheduleHandler handles POST /schedule/explain. The body is a task
+// event in the same form as POST /tasks; the response describes, per node,
+// each filter verdict and score, and the node that would be picked.
+func (a *Api) ExplainScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// The leader holds the node inventory and in-flight placements, so its
+	// answer matches what it would actually do.
+	if a.forwardToLeader(w, r) {
+		return
+	}
+
+	te := task.TaskEvent{}
+	if err := json.NewDecoder(r.Body).Decode(&te); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: fmt.Sprintf("Error unmarshalling body: %v", err)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	explanation, err := a.Manager.ExplainPlacement(ctx, te.Task)
+	if errors.Is(err, ErrUnknownSchedulerProfile) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusServiceUnavailable, Message: fmt.Sprintf("unable to explain placement: %v", err)})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(explanation)
+}
@@ -0,0 +1,173 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/appgroup"
+	"github.com/aditip149209/okube/pkg/scheduler"
+	"github.com/aditip149209/okube/pkg/store"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/google/uuid"
+)
+
+// appPlacement is where every service of an app is to run.
+type appPlacement struct {
+	// workers maps each service to the worker it is placed on.
+	workers map[string]store.Worker
+	gang    *scheduler.GangPlacement
+}
+
+// gangPlacementAttempts bounds how often placeApp searches again when the
+// cluster changed under a placement before it could be recorded.
+const gangPlacementAttempts = 3
+
+// placeApp places every service of an app at once, so that an early choice
+// cannot leave a later service without a node. The search runs without
+// holding up other placements; the result is then re-checked against the
+// current inventory and recorded as in flight in one step. A
+// *scheduler.GangError says why no placement satisfies the app's hard
+// constraints.
+func (m *Manager) placeApp(ctx context.Context, ag *appgroup.AppGroup, tasks map[string]*task.Task) (*appPlacement, error) {
+	group := make([]task.Task, 0, len(tasks))
+	byService := make(map[string]task.Task, len(tasks))
+	for name, t := range tasks {
+		group = append(group, *t)
+		byService[name] = *t
+	}
+
+	// The app's own tasks are not stored yet, so the zero task gives the
+	// cluster-wide context: topology, placed tasks and nodes.
+	request := func() (*schedulingInputs, scheduler.GangRequest, error) {
+		in, err := m.schedulingInputs(ctx, task.Task{})
+		if err != nil {
+			return nil, scheduler.GangRequest{}, err
+		}
+		return in, scheduler.GangRequest{
+			AppGroup:     ag,
+			Tasks:        byService,
+			Nodes:        in.nodes,
+			Filter:       in.scoreCtx.Filter,
+			SchedulerFor: m.schedulerFor,
+		}, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		_, req, err := request()
+		if err != nil {
+			return nil, err
+		}
+		gang, err := scheduler.PlaceGang(req)
+		if err != nil {
+			return nil, err
+		}
+
+		p := &appPlacement{workers: make(map[string]store.Worker, len(tasks)), gang: gang}
+		stale := ""
+		_, err = m.inventory.PlaceGroup(group, func() (map[uuid.UUID]string, error) {
+			in, req, err := request()
+			if err != nil {
+				return nil, err
+			}
+			reason, err := scheduler.CheckGang(req, gang)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				stale = reason
+				return nil, fmt.Errorf("placement of app %s no longer holds: %s", ag.AppID, reason)
+			}
+
+			assigned := make(map[uuid.UUID]string, len(gang.Nodes))
+			for name, nodeName := range gang.Nodes {
+				w, ok := in.workers[nodeName]
+				if !ok {
+					return nil, fmt.Errorf("selected worker %s not found", nodeName)
+				}
+				p.workers[name] = w
+				assigned[byService[name].ID] = w.ID
+			}
+			return assigned, nil
+		})
+		if err == nil {
+			return p, nil
+		}
+		if stale == "" || attempt == gangPlacementAttempts {
+			return nil, err
+		}
+		log.Printf("Manager %s: placement of app %s went stale (%s); searching again", m.ID, ag.AppID, stale)
+	}
+}
+
+// releaseAppPlacement forgets the in-flight placements of tasks that were
+// never recorded.
+func (m *Manager) releaseAppPlacement(tasks map[string]*task.Task) {
+	for _, t := range tasks {
+		m.inventory.Release(t.ID)
+	}
+}
+
+// startAppTask hands a placed task to its worker and marks it Running once
+// the worker acknowledges it.
+func (m *Manager) startAppTask(ctx context.Context, t *task.Task, w store.Worker) error {
+	te := task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now().UTC(),
+		Task:      *t,
+	}
+
+	_, errResp, err := m.WorkerClient.StartTask(w.Address, te)
+	if err != nil {
+		return fmt.Errorf("dispatch to %s failed: %w", w.ID, err)
+	}
+	if errResp != nil {
+		return fmt.Errorf("worker %s rejected the task: %s", w.ID, errResp.Message)
+	}
+
+	t.State = task.Running
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	if err := m.Store.UpdateTaskState(updateCtx, t, w.ID); err != nil {
+		log.Printf("Manager %s: failed to mark task %s running: %v", m.ID, t.ID, err)
+	}
+	cancel()
+	return nil
+}
+
+// rollbackApp undoes an app deployment that could not be dispatched in
+// full. Tasks already started are stopped, every task is recorded as
+// stopped with the cause, and their resources and bandwidth are released.
+func (m *Manager) rollbackApp(ctx context.Context, app *store.App, records []store.TaskRecord, p *appPlacement, started map[uuid.UUID]bool, cause error) {
+	now := time.Now().UTC()
+	for _, rec := range records {
+		t := rec.Task
+		if started[t.ID] {
+			if w, ok := p.workers[t.ServiceID]; ok {
+				if err := m.WorkerClient.StopTask(w.Address, t.ID.String()); err != nil {
+					log.Printf("Manager %s: rollback: stopping task %s on worker %s: %v", m.ID, t.ID, w.ID, err)
+				}
+			}
+		}
+
+		t.State = task.Completed
+		t.EndTime = now
+		failSchedulingAttempt(t, fmt.Errorf("app deployment rolled back: %w", cause))
+		updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := m.Store.UpdateTaskState(updateCtx, t, rec.WorkerID); err != nil {
+			log.Printf("Manager %s: rollback: recording task %s as stopped: %v", m.ID, t.ID, err)
+		}
+		cancel()
+
+		m.inventory.Release(t.ID)
+		m.releaseBandwidthForTask(t.ID)
+	}
+
+	app.Status = "failed"
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	_ = m.Store.UpdateApp(updateCtx, app)
+	cancel()
+
+	log.Printf("Manager %s: app %s rolled back: %v", m.ID, app.Name, cause)
+}
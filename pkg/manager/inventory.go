@@ -0,0 +1,278 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/node"
+	"github.com/aditip149209/okube/pkg/store"
+	"github.com/aditip149209/okube/pkg/task"
+	workerpkg "github.com/aditip149209/okube/pkg/worker"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultInventoryInterval is how often worker stats and allocations
+	// are refreshed in the background.
+	defaultInventoryInterval = 15 * time.Second
+	// inFlightTTL bounds how long a placement is counted before the store
+	// shows it; it covers decisions whose outcome was never reported.
+	inFlightTTL = 2 * time.Minute
+)
+
+// NodeInventory is the manager's cached view of every worker: its latest
+// stats snapshot and the resources already promised to tasks on it. Stats
+// are fetched in the background so scheduling decisions do not wait on
+// workers, and placements that are decided but not yet visible in the store
+// are tracked as in flight so concurrent decisions do not double-book.
+type NodeInventory struct {
+	mu       sync.RWMutex
+	entries  map[string]*inventoryEntry
+	inFlight map[uuid.UUID]inFlightPlacement
+
+	// placeMu serialises whole placement decisions (read inventory, pick,
+	// record in flight).
+	placeMu sync.Mutex
+}
+
+type inventoryEntry struct {
+	stats     *workerpkg.Stats
+	statsAt   time.Time
+	allocated allocation
+	// scheduledMemory is the memory (KB) of tasks the store shows as
+	// Scheduled, which the worker's measured usage does not include yet.
+	scheduledMemory int
+}
+
+// allocation is the memory (KB), disk, CPU cores and task count promised
+// on a worker.
+type allocation struct {
+	memory int
+	disk   int
+	cpu    int
+	tasks  int
+}
+
+type inFlightPlacement struct {
+	workerID  string
+	allocated allocation
+	at        time.Time
+}
+
+// NewNodeInventory returns an empty inventory.
+func NewNodeInventory() *NodeInventory {
+	return &NodeInventory{
+		entries:  make(map[string]*inventoryEntry),
+		inFlight: make(map[uuid.UUID]inFlightPlacement),
+	}
+}
+
+func taskAllocation(t *task.Task) allocation {
+	// Task memory is converted to KB the same way the EPVM scorer does.
+	return allocation{memory: t.Memory / 1000, disk: t.Disk, cpu: t.Cpu, tasks: 1}
+}
+
+// Nodes returns scheduler nodes for the given workers, populated with the
+// cached stats and with allocations that include in-flight placements.
+// Workers without a stats snapshot yet get no cached stats, so scorers fall
+// back to asking the worker directly.
+func (inv *NodeInventory) Nodes(workers []store.Worker) []*node.Node {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	pending := make(map[string]allocation)
+	for _, p := range inv.inFlight {
+		a := pending[p.workerID]
+		a.memory += p.allocated.memory
+		a.disk += p.allocated.disk
+		a.cpu += p.allocated.cpu
+		a.tasks += p.allocated.tasks
+		pending[p.workerID] = a
+	}
+
+	nodes := make([]*node.Node, 0, len(workers))
+	for _, w := range workers {
+		n := nodeFromWorker(w)
+		total := pending[w.ID]
+		notStarted := pending[w.ID].memory
+		if e, ok := inv.entries[w.ID]; ok {
+			if e.stats != nil {
+				node.WithStats(*e.stats, e.statsAt)(n)
+			}
+			notStarted += e.scheduledMemory
+			total.memory += e.allocated.memory
+			total.disk += e.allocated.disk
+			total.cpu += e.allocated.cpu
+			total.tasks += e.allocated.tasks
+		}
+		node.WithAllocations(total.memory, total.disk, total.tasks)(n)
+		node.WithCpuAllocation(total.cpu)(n)
+		node.WithMemoryInFlight(notStarted)(n)
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// Place runs decide while no other placement decision is in progress and
+// records the task as in flight on the worker it returns, if any.
+func (inv *NodeInventory) Place(t task.Task, decide func() (*store.Worker, error)) (*store.Worker, error) {
+	inv.placeMu.Lock()
+	defer inv.placeMu.Unlock()
+
+	w, err := decide()
+	if err != nil || w == nil {
+		return w, err
+	}
+
+	inv.mu.Lock()
+	inv.inFlight[t.ID] = inFlightPlacement{workerID: w.ID, allocated: taskAllocation(&t), at: time.Now()}
+	inv.mu.Unlock()
+	return w, nil
+}
+
+// PlaceGroup is Place for tasks decided together: decide returns the worker
+// ID of each task, and every task it assigns is recorded as in flight.
+func (inv *NodeInventory) PlaceGroup(tasks []task.Task, decide func() (map[uuid.UUID]string, error)) (map[uuid.UUID]string, error) {
+	inv.placeMu.Lock()
+	defer inv.placeMu.Unlock()
+
+	assigned, err := decide()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	inv.mu.Lock()
+	for i := range tasks {
+		if workerID, ok := assigned[tasks[i].ID]; ok {
+			inv.inFlight[tasks[i].ID] = inFlightPlacement{workerID: workerID, allocated: taskAllocation(&tasks[i]), at: now}
+		}
+	}
+	inv.mu.Unlock()
+	return assigned, nil
+}
+
+// Release forgets an in-flight placement that did not go ahead.
+func (inv *NodeInventory) Release(taskID uuid.UUID) {
+	inv.mu.Lock()
+	delete(inv.inFlight, taskID)
+	inv.mu.Unlock()
+}
+
+// Refresh fetches stats from every worker in parallel and recomputes
+// allocations from the tasks the store shows as Scheduled or Running.
+// In-flight placements the store now reflects are dropped.
+func (inv *NodeInventory) Refresh(ctx context.Context, workers []store.Worker, records []store.TaskRecord, fetch func(ctx context.Context, address string) (*workerpkg.Stats, error)) {
+	type result struct {
+		id    string
+		stats *workerpkg.Stats
+		at    time.Time
+	}
+	results := make(chan result, len(workers))
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w store.Worker) {
+			defer wg.Done()
+			stats, err := fetch(ctx, w.Address)
+			if err != nil {
+				log.Printf("Inventory: failed to fetch stats from worker %s: %v", w.ID, err)
+				return
+			}
+			results <- result{id: w.ID, stats: stats, at: time.Now()}
+		}(w)
+	}
+	wg.Wait()
+	close(results)
+
+	allocated := make(map[string]allocation)
+	scheduledMemory := make(map[string]int)
+	placed := make(map[uuid.UUID]string)
+	for _, rec := range records {
+		if rec.Task == nil || rec.WorkerID == "" {
+			continue
+		}
+		if rec.Task.State != task.Scheduled && rec.Task.State != task.Running {
+			continue
+		}
+		a := allocated[rec.WorkerID]
+		ta := taskAllocation(rec.Task)
+		a.memory += ta.memory
+		a.disk += ta.disk
+		a.cpu += ta.cpu
+		a.tasks += ta.tasks
+		allocated[rec.WorkerID] = a
+		if rec.Task.State == task.Scheduled {
+			scheduledMemory[rec.WorkerID] += ta.memory
+		}
+		placed[rec.Task.ID] = rec.WorkerID
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	live := make(map[string]bool, len(workers))
+	for _, w := range workers {
+		live[w.ID] = true
+		e, ok := inv.entries[w.ID]
+		if !ok {
+			e = &inventoryEntry{}
+			inv.entries[w.ID] = e
+		}
+		e.allocated = allocated[w.ID]
+		e.scheduledMemory = scheduledMemory[w.ID]
+	}
+	for id := range inv.entries {
+		if !live[id] {
+			delete(inv.entries, id)
+		}
+	}
+	for r := range results {
+		if e, ok := inv.entries[r.id]; ok {
+			e.stats = r.stats
+			e.statsAt = r.at
+		}
+	}
+
+	now := time.Now()
+	for id, p := range inv.inFlight {
+		if workerID, ok := placed[id]; ok && workerID == p.workerID {
+			delete(inv.inFlight, id)
+			continue
+		}
+		if now.Sub(p.at) > inFlightTTL {
+			delete(inv.inFlight, id)
+		}
+	}
+}
+
+// refreshInventory refreshes the inventory once from the store and workers.
+func (m *Manager) refreshInventory(ctx context.Context) error {
+	workers, err := m.activeWorkers(ctx)
+	if err != nil {
+		return err
+	}
+	records, err := m.Store.ListTasks(ctx)
+	if err != nil {
+		return err
+	}
+	m.inventory.Refresh(ctx, workers, records, m.WorkerClient.FetchStats)
+	return nil
+}
+
+// RefreshInventory keeps the node inventory current. Only the leader
+// schedules, so followers skip the work.
+func (m *Manager) RefreshInventory() {
+	for {
+		if m.IsLeader() && m.Store != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := m.refreshInventory(ctx); err != nil {
+				log.Printf("Manager %s: inventory refresh failed: %v", m.ID, err)
+			}
+			cancel()
+		}
+		time.Sleep(m.inventoryInterval)
+	}
+}
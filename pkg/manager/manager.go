@@ -9,6 +9,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +21,7 @@ import (
 	"github.com/aditip149209/okube/pkg/store"
 	"github.com/aditip149209/okube/pkg/task"
 	"github.com/aditip149209/okube/pkg/topology"
+	workerpkg "github.com/aditip149209/okube/pkg/worker"
 	"github.com/docker/go-connections/nat"
 	"github.com/go-chi/chi"
 	"github.com/golang-collections/collections/queue"
@@ -40,6 +43,10 @@ const (
 
 var ErrNotLeader = errors.New("manager: not leader")
 
+// ErrUnknownSchedulerProfile is returned for a task naming a scheduler
+// profile the manager was not started with.
+var ErrUnknownSchedulerProfile = errors.New("unknown scheduler profile")
+
 type managerInfo struct {
 	ID        string    `json:"id"`
 	Address   string    `json:"address,omitempty"`
@@ -64,26 +71,61 @@ func decodeManagerInfo(payload []byte) (*managerInfo, error) {
 }
 
 type Config struct {
-	Workers                 []string
-	SchedulerType           string
-	QueueSortStrategy       string
+	Workers           []string
+	SchedulerType     string
+	QueueSortStrategy string
+	// SchedulerProfiles are extra scheduler profiles tasks can select with
+	// schedulerProfile. A profile named "default" replaces the one built
+	// from SchedulerType.
+	SchedulerProfiles       []scheduler.Profile
 	TopologyProbeMode       string
 	TopologyProbeInterval   time.Duration
 	TopologyProbeSampleSize int
-	Store                   store.Store
-	Role                    ManagerRole
-	ID                      string
-	AdvertiseAddr           string
-	WorkerClient            WorkerCommunicator
+	TopologyProbeSamples    int
+	// TopologyProbeTransport selects how workers measure RTT to each other
+	// (http, tcp or udp).
+	TopologyProbeTransport string
+	// TopologyBandwidthProbeInterval and TopologyBandwidthProbeBytes control
+	// the periodic worker-to-worker throughput probes.
+	TopologyBandwidthProbeInterval time.Duration
+	TopologyBandwidthProbeBytes    int64
+	// TopologyLatencyWindow and TopologyEWMAAlpha shape the per-link
+	// latency model; FilterLatencyStatistic and ScoreLatencyStatistic pick
+	// which statistic the network plugins use (latest, ewma, p50, p95).
+	TopologyLatencyWindow  int
+	TopologyEWMAAlpha      float64
+	FilterLatencyStatistic string
+	ScoreLatencyStatistic  string
+	// BinpackWeights weigh memory, CPU and disk for the binpack scheduler;
+	// the zero value weighs them equally.
+	BinpackWeights scheduler.BinpackWeights
+	// TopologyLatencyTTL and TopologyBandwidthTTL are how long a measured
+	// link stays usable by the scheduler; older links count as unknown.
+	TopologyLatencyTTL   time.Duration
+	TopologyBandwidthTTL time.Duration
+	// TopologyFailureThreshold is how many consecutive lost probes mark a
+	// link down when computing network partitions.
+	TopologyFailureThreshold int
+	// InventoryRefreshInterval is how often worker stats and allocations
+	// are refreshed for the scheduler.
+	InventoryRefreshInterval time.Duration
+	Store                    store.Store
+	Role                     ManagerRole
+	ID                       string
+	AdvertiseAddr            string
+	WorkerClient             WorkerCommunicator
 }
 
 type Manager struct {
-	ID                  string
-	Role                ManagerRole
-	AdvertiseAddr       string
-	roleMu              sync.RWMutex
-	Pending             queue.Queue
-	Scheduler           scheduler.Scheduler
+	ID            string
+	Role          ManagerRole
+	AdvertiseAddr string
+	roleMu        sync.RWMutex
+	Pending       queue.Queue
+	Scheduler     scheduler.Scheduler
+	// profiles are the schedulers for named profiles; Scheduler is the
+	// default one.
+	profiles            map[string]scheduler.Scheduler
 	WorkerClient        WorkerCommunicator
 	Store               store.Store
 	initialWorkers      []string
@@ -95,6 +137,17 @@ type Manager struct {
 	taskWatchStop       context.CancelFunc
 	topologyUpdater     *topology.Updater
 	topologyUpdaterStop context.CancelFunc
+	// topologyProbeSamples is the number of RTT samples a worker takes per
+	// latency probe.
+	topologyProbeSamples   int
+	topologyProbeTransport topology.ProbeTransport
+	// topologyBandwidthProbeBytes is the payload size of a bandwidth probe.
+	topologyBandwidthProbeBytes int64
+	topologyLatencyTTL          time.Duration
+	topologyBandwidthTTL        time.Duration
+	// inventory caches worker stats and allocations for SelectWorker.
+	inventory         *NodeInventory
+	inventoryInterval time.Duration
 }
 
 func (m *Manager) startLeaderElection() {
@@ -319,30 +372,42 @@ func (m *Manager) listTopologyNodes(ctx context.Context) ([]topology.NodeTarget,
 	return nodes, nil
 }
 
+// probeLatency asks the "from" worker to measure its round-trip time to the
+// "to" worker, so NodeLatencies[from][to] describes the actual link between
+// the two workers rather than the manager's view of "to".
 func (m *Manager) probeLatency(ctx context.Context, from topology.NodeTarget, to topology.NodeTarget) (float64, error) {
 	if from.ID == to.ID {
 		return 0, nil
 	}
 
-	url := fmt.Sprintf("http://%s/stats", to.Address)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	probeCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	result, err := m.WorkerClient.ProbeLatency(probeCtx, from.Address, to.Address, m.topologyProbeSamples, m.topologyProbeTransport)
 	if err != nil {
 		return 0, err
 	}
 
-	start := time.Now()
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return 0, err
+	return result.MedianMs, nil
+}
+
+// probeBandwidth asks the "from" worker to push a fixed payload to the "to"
+// worker and returns the achieved throughput in Mbps.
+func (m *Manager) probeBandwidth(ctx context.Context, from topology.NodeTarget, to topology.NodeTarget) (float64, error) {
+	if from.ID == to.ID {
+		return 0, nil
 	}
-	defer resp.Body.Close()
-	_, _ = io.Copy(io.Discard, resp.Body)
 
-	if resp.StatusCode >= 400 {
-		return 0, fmt.Errorf("probe failed with status %d", resp.StatusCode)
+	probeCtx, cancel := context.WithTimeout(ctx, 90*time.Second)
+	defer cancel()
+
+	result, err := m.WorkerClient.ProbeBandwidth(probeCtx, from.Address, to.Address, m.topologyBandwidthProbeBytes)
+	if err != nil {
+		log.Printf("Manager %s: bandwidth probe %s -> %s failed: %v", m.ID, from.ID, to.ID, err)
+		return 0, err
 	}
 
-	return float64(time.Since(start)) / float64(time.Millisecond), nil
+	return result.Mbps, nil
 }
 
 func (m *Manager) watchPendingTasks(ctx context.Context) {
@@ -471,23 +536,28 @@ func (m *Manager) trySchedulePendingTask(taskID uuid.UUID) {
 	}
 
 	workerCtx, workerCancel := context.WithTimeout(context.Background(), 5*time.Second)
-	worker, err := m.SelectWorker(workerCtx, *t)
+	p, err := m.selectPlacement(workerCtx, *t)
 	workerCancel()
+	recordSchedulingAttempt(t, p, err)
 	if err != nil {
 		log.Printf("Manager %s: no worker selected for task %s: %v", m.ID, t.ID, err)
+		m.saveSchedulingStatus(t)
 		return
 	}
+	worker := p.worker
 
 	assignCtx, assignCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	succeeded, err := m.etcdStore.AssignPendingTask(assignCtx, t, worker.ID)
 	assignCancel()
 	if err != nil {
 		log.Printf("Manager %s: failed to assign task %s to worker %s: %v", m.ID, t.ID, worker.ID, err)
+		m.inventory.Release(t.ID)
 		return
 	}
 
 	if !succeeded {
 		log.Printf("Manager %s: task %s was already scheduled by another manager", m.ID, t.ID)
+		m.inventory.Release(t.ID)
 		return
 	}
 
@@ -496,6 +566,7 @@ func (m *Manager) trySchedulePendingTask(taskID uuid.UUID) {
 	reserveCancel()
 	if err != nil {
 		log.Printf("Manager %s: failed bandwidth reservation for task %s on worker %s: %v", m.ID, t.ID, worker.ID, err)
+		failSchedulingAttempt(t, fmt.Errorf("bandwidth reservation on %s failed: %w", worker.ID, err))
 		m.resetTaskToPending(*t)
 		return
 	}
@@ -519,22 +590,14 @@ func (m *Manager) dispatchTaskToWorker(t task.Task, worker *store.Worker) {
 	_, errResp, err := m.WorkerClient.StartTask(worker.Address, te)
 	if err != nil {
 		log.Printf("Manager %s: dispatch to worker %s for task %s failed: %v", m.ID, worker.ID, t.ID, err)
-		releaseCtx, releaseCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		if releaseErr := m.releaseBandwidthForTaskPlacement(releaseCtx, t, worker.ID); releaseErr != nil {
-			log.Printf("Manager %s: failed to release bandwidth for task %s after dispatch error: %v", m.ID, t.ID, releaseErr)
-		}
-		releaseCancel()
+		failSchedulingAttempt(&t, fmt.Errorf("dispatch to %s failed: %w", worker.ID, err))
 		m.resetTaskToPending(t)
 		return
 	}
 
 	if errResp != nil {
 		log.Printf("Manager %s: worker %s rejected task %s: %s", m.ID, worker.ID, t.ID, errResp.Message)
-		releaseCtx, releaseCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		if releaseErr := m.releaseBandwidthForTaskPlacement(releaseCtx, t, worker.ID); releaseErr != nil {
-			log.Printf("Manager %s: failed to release bandwidth for task %s after worker rejection: %v", m.ID, t.ID, releaseErr)
-		}
-		releaseCancel()
+		failSchedulingAttempt(&t, fmt.Errorf("worker %s rejected the task: %s", worker.ID, errResp.Message))
 		m.resetTaskToPending(t)
 		return
 	}
@@ -559,6 +622,11 @@ func (m *Manager) resetTaskToPending(t task.Task) {
 		log.Printf("Manager %s: failed to revert task %s to pending: %v", m.ID, t.ID, err)
 	}
 	cancel()
+
+	// A pending task holds no placement, so it holds no resources or
+	// bandwidth either.
+	m.inventory.Release(t.ID)
+	m.releaseBandwidthForTask(t.ID)
 }
 
 func (m *Manager) activeWorkers(ctx context.Context) ([]store.Worker, error) {
@@ -586,74 +654,163 @@ func (m *Manager) activeWorkers(ctx context.Context) ([]store.Worker, error) {
 	return live, nil
 }
 
-func (m *Manager) SelectWorker(ctx context.Context, t task.Task) (*store.Worker, error) {
-	if m.Store == nil {
-		return nil, errors.New("store not configured")
-	}
-
+// schedulableWorkers returns the live workers that may receive new tasks,
+// i.e. those that are not draining.
+func (m *Manager) schedulableWorkers(ctx context.Context) ([]store.Worker, error) {
 	workers, err := m.activeWorkers(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	workerMap := make(map[string]store.Worker)
-	nodes := make([]*node.Node, 0, len(workers))
+	ready := make([]store.Worker, 0, len(workers))
 	for _, w := range workers {
-		workerMap[w.ID] = w
-		n := node.NewNode(w.ID, w.Address, "worker")
-		nodes = append(nodes, n)
+		if !w.Draining {
+			ready = append(ready, w)
+		}
 	}
 
-	filterCtx := m.buildFilterContext(ctx, t)
-	candidates := m.Scheduler.SelectCandidateNodes(t, nodes, filterCtx)
-	if len(candidates) == 0 {
-		return nil, fmt.Errorf("no available candidates match resource request for task %v", t.ID)
+	if len(ready) == 0 {
+		return nil, errors.New("all live workers are draining")
 	}
 
-	scoreCtx := &scheduler.ScoreContext{
-		Filter:         filterCtx,
-		NetworkWeight:  0.7,
-		ResourceWeight: 0.3,
+	return ready, nil
+}
+
+func (m *Manager) SelectWorker(ctx context.Context, t task.Task) (*store.Worker, error) {
+	p, err := m.selectPlacement(ctx, t)
+	if err != nil {
+		return nil, err
 	}
-	scores := m.Scheduler.Score(t, candidates, scoreCtx)
-	selectedNode := m.Scheduler.Pick(scores, candidates)
-	if selectedNode == nil {
-		return nil, fmt.Errorf("scheduler failed to pick a worker for task %v", t.ID)
+	return p.worker, nil
+}
+
+// selectPlacement picks a worker for t and reports the score it won with.
+// When no node passes the filters the error says which filters rejected
+// how many nodes, if the scheduler can explain itself.
+func (m *Manager) selectPlacement(ctx context.Context, t task.Task) (*placement, error) {
+	if m.Store == nil {
+		return nil, errors.New("store not configured")
 	}
 
-	selectedWorker, ok := workerMap[selectedNode.Name]
-	if !ok {
-		return nil, fmt.Errorf("selected worker %s not found", selectedNode.Name)
+	sched, err := m.schedulerFor(t)
+	if err != nil {
+		return nil, err
 	}
 
-	return &selectedWorker, nil
+	var score *float64
+	w, err := m.inventory.Place(t, func() (*store.Worker, error) {
+		in, err := m.schedulingInputs(ctx, t)
+		if err != nil {
+			return nil, err
+		}
 
-}
+		candidates := sched.SelectCandidateNodes(t, in.nodes, in.scoreCtx.Filter)
+		if len(candidates) == 0 {
+			if explainer, ok := sched.(scheduler.Explainer); ok {
+				if summary := explainer.Explain(t, in.nodes, in.scoreCtx.Filter, in.scoreCtx).Summary(); summary != "" {
+					return nil, errors.New(summary)
+				}
+			}
+			return nil, fmt.Errorf("no available candidates match resource request for task %v", t.ID)
+		}
 
-func (m *Manager) buildFilterContext(ctx context.Context, t task.Task) *scheduler.FilterContext {
-	if m.Store == nil || t.AppID == "" {
-		return nil
+		scoreCtx := in.scoreCtx
+		scores := sched.Score(t, candidates, scoreCtx)
+		selectedNode := sched.Pick(scores, candidates)
+		if selectedNode == nil {
+			return nil, fmt.Errorf("scheduler failed to pick a worker for task %v", t.ID)
+		}
+
+		selectedWorker, ok := in.workers[selectedNode.Name]
+		if !ok {
+			return nil, fmt.Errorf("selected worker %s not found", selectedNode.Name)
+		}
+
+		if s, ok := scores[selectedNode.Name]; ok {
+			score = &s
+		}
+		return &selectedWorker, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &placement{worker: w, score: score}, nil
+}
 
-	serviceID := taskServiceID(&t)
-	if serviceID == "" {
-		return nil
+// schedulerFor returns the scheduler for the profile t names, or the
+// default scheduler when it names none.
+func (m *Manager) schedulerFor(t task.Task) (scheduler.Scheduler, error) {
+	if t.SchedulerProfile == "" || t.SchedulerProfile == scheduler.DefaultProfileName {
+		return m.Scheduler, nil
 	}
+	s, ok := m.profiles[t.SchedulerProfile]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSchedulerProfile, t.SchedulerProfile)
+	}
+	return s, nil
+}
 
-	ag, err := m.Store.GetAppGroup(ctx, t.AppID)
+// schedulingInputs is everything a placement decision for one task reads.
+type schedulingInputs struct {
+	workers  map[string]store.Worker
+	nodes    []*node.Node
+	scoreCtx *scheduler.ScoreContext
+}
+
+// schedulingInputs gathers the schedulable workers as inventory nodes and
+// the filter and score contexts for t.
+func (m *Manager) schedulingInputs(ctx context.Context, t task.Task) (*schedulingInputs, error) {
+	workers, err := m.schedulableWorkers(ctx)
 	if err != nil {
-		if !errors.Is(err, store.ErrNotFound) {
-			log.Printf("Manager %s: failed to load appgroup %s for filter context: %v", m.ID, t.AppID, err)
-		}
+		return nil, err
+	}
+
+	workerMap := make(map[string]store.Worker)
+	for _, w := range workers {
+		workerMap[w.ID] = w
+	}
+	nodes := m.inventory.Nodes(workers)
+
+	filterCtx := m.buildFilterContext(ctx, t)
+	if filterCtx == nil {
+		filterCtx = &scheduler.FilterContext{}
+	}
+	filterCtx.Nodes = nodes
+
+	return &schedulingInputs{
+		workers: workerMap,
+		nodes:   nodes,
+		// Weights are left to the task's scheduler profile.
+		scoreCtx: &scheduler.ScoreContext{
+			Filter: filterCtx,
+		},
+	}, nil
+}
+
+// nodeFromWorker converts a worker registration into the node representation
+// consumed by scheduler plugins, carrying over labels and capacity.
+func nodeFromWorker(w store.Worker) *node.Node {
+	return node.NewNode(w.ID, w.Address, "worker",
+		node.WithCore(w.Capacity.Cores),
+		node.WithMemory(int(w.Capacity.MemoryKb)),
+		node.WithDisk(int(w.Capacity.DiskBytes)),
+		node.WithLabels(w.Labels),
+		node.WithPlatform(w.Arch, w.OS),
+		node.WithTaints(w.Taints),
+	)
+}
+
+func (m *Manager) buildFilterContext(ctx context.Context, t task.Task) *scheduler.FilterContext {
+	if m.Store == nil {
 		return nil
 	}
 
-	topo, err := m.Store.GetNetworkTopology(ctx)
+	topo, err := m.schedulingTopology(ctx)
 	if err != nil {
 		if !errors.Is(err, store.ErrNotFound) {
 			log.Printf("Manager %s: failed to load network topology for filter context: %v", m.ID, err)
 		}
-		return nil
+		topo = nil
 	}
 
 	records, err := m.Store.ListTasks(ctx)
@@ -662,6 +819,32 @@ func (m *Manager) buildFilterContext(ctx context.Context, t task.Task) *schedule
 		return nil
 	}
 
+	// Every placed task, of any app, for plugins that place a task relative
+	// to others (affinity, spread).
+	filterCtx := &scheduler.FilterContext{NetworkTopology: topo}
+	for _, rec := range records {
+		if rec.Task == nil || rec.WorkerID == "" || rec.Task.ID == t.ID {
+			continue
+		}
+		if rec.Task.State != task.Running && rec.Task.State != task.Scheduled {
+			continue
+		}
+		filterCtx.Placements = append(filterCtx.Placements, scheduler.TaskPlacement{Task: rec.Task, Node: rec.WorkerID})
+	}
+
+	serviceID := taskServiceID(&t)
+	if t.AppID == "" || serviceID == "" || topo == nil {
+		return filterCtx
+	}
+
+	ag, err := m.Store.GetAppGroup(ctx, t.AppID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Manager %s: failed to load appgroup %s for filter context: %v", m.ID, t.AppID, err)
+		}
+		return filterCtx
+	}
+
 	dependencyNodeByService := make(map[string]string)
 	for _, rec := range records {
 		if rec.Task == nil || rec.Task.AppID != t.AppID {
@@ -686,11 +869,9 @@ func (m *Manager) buildFilterContext(ctx context.Context, t task.Task) *schedule
 		dependencyNodeByService[sid] = nodeID
 	}
 
-	return &scheduler.FilterContext{
-		AppGroup:                ag,
-		NetworkTopology:         topo,
-		DependencyNodeByService: dependencyNodeByService,
-	}
+	filterCtx.AppGroup = ag
+	filterCtx.DependencyNodeByService = dependencyNodeByService
+	return filterCtx
 }
 
 func taskServiceID(t *task.Task) string {
@@ -703,95 +884,6 @@ func taskServiceID(t *task.Task) string {
 	return t.Name
 }
 
-func (m *Manager) reserveBandwidthForTaskPlacement(ctx context.Context, t task.Task, candidateNodeID string) error {
-	if m.Store == nil || t.AppID == "" {
-		return nil
-	}
-
-	filterCtx := m.buildFilterContext(ctx, t)
-	if filterCtx == nil || filterCtx.AppGroup == nil || filterCtx.NetworkTopology == nil {
-		return nil
-	}
-
-	serviceID := taskServiceID(&t)
-	if serviceID == "" {
-		return nil
-	}
-
-	edges := filterCtx.AppGroup.GetDependencies(serviceID)
-	type reservation struct {
-		nodeA  string
-		nodeB  string
-		amount float64
-	}
-	reserved := make([]reservation, 0)
-
-	for _, edge := range edges {
-		if edge.MinBandwidth == nil || *edge.MinBandwidth <= 0 {
-			continue
-		}
-
-		depNodeID, ok := filterCtx.DependencyNodeByService[edge.To]
-		if !ok || depNodeID == "" || depNodeID == candidateNodeID {
-			continue
-		}
-
-		if ok := filterCtx.NetworkTopology.ReserveBandwidth(candidateNodeID, depNodeID, *edge.MinBandwidth); !ok {
-			for _, r := range reserved {
-				filterCtx.NetworkTopology.ReleaseBandwidth(r.nodeA, r.nodeB, r.amount)
-			}
-			return fmt.Errorf("insufficient available bandwidth from %s to %s", candidateNodeID, depNodeID)
-		}
-
-		reserved = append(reserved, reservation{nodeA: candidateNodeID, nodeB: depNodeID, amount: *edge.MinBandwidth})
-	}
-
-	if len(reserved) == 0 {
-		return nil
-	}
-
-	return m.Store.SaveNetworkTopology(ctx, filterCtx.NetworkTopology)
-}
-
-func (m *Manager) releaseBandwidthForTaskPlacement(ctx context.Context, t task.Task, candidateNodeID string) error {
-	if m.Store == nil || t.AppID == "" {
-		return nil
-	}
-
-	filterCtx := m.buildFilterContext(ctx, t)
-	if filterCtx == nil || filterCtx.AppGroup == nil || filterCtx.NetworkTopology == nil {
-		return nil
-	}
-
-	serviceID := taskServiceID(&t)
-	if serviceID == "" {
-		return nil
-	}
-
-	edges := filterCtx.AppGroup.GetDependencies(serviceID)
-	releasedAny := false
-	for _, edge := range edges {
-		if edge.MinBandwidth == nil || *edge.MinBandwidth <= 0 {
-			continue
-		}
-
-		depNodeID, ok := filterCtx.DependencyNodeByService[edge.To]
-		if !ok || depNodeID == "" || depNodeID == candidateNodeID {
-			continue
-		}
-
-		if filterCtx.NetworkTopology.ReleaseBandwidth(candidateNodeID, depNodeID, *edge.MinBandwidth) {
-			releasedAny = true
-		}
-	}
-
-	if !releasedAny {
-		return nil
-	}
-
-	return m.Store.SaveNetworkTopology(ctx, filterCtx.NetworkTopology)
-}
-
 func (m *Manager) updateTasks() {
 	if !m.IsLeader() {
 		log.Printf("Manager %s is in follower role; skipping task state updates", m.ID)
@@ -822,7 +914,7 @@ func (m *Manager) updateTasks() {
 			log.Printf("Attempting to update task %v\n", t)
 
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			persisted, _, err := m.Store.GetTask(ctx, t.ID)
+			persisted, assignedWorker, err := m.Store.GetTask(ctx, t.ID)
 			cancel()
 			if err != nil {
 				if errors.Is(err, store.ErrNotFound) {
@@ -833,6 +925,13 @@ func (m *Manager) updateTasks() {
 				continue
 			}
 
+			// A task that was reset to Pending or moved to another worker
+			// (e.g. after a drain) is no longer owned by this worker, so its
+			// local view must not overwrite the stored state.
+			if persisted.State == task.Pending || (assignedWorker != "" && assignedWorker != worker.ID) {
+				continue
+			}
+
 			persisted.State = t.State
 			persisted.StartTime = t.StartTime
 			persisted.EndTime = t.EndTime
@@ -843,6 +942,10 @@ func (m *Manager) updateTasks() {
 				log.Printf("Error updating task %s in store: %v", t.ID, err)
 			}
 			cancel()
+
+			if persisted.State == task.Completed || persisted.State == task.Failed {
+				m.releaseBandwidthForTask(persisted.ID)
+			}
 		}
 	}
 
@@ -892,31 +995,42 @@ func (m *Manager) SendWork() {
 		return
 	}
 
-	t := te.Task
 	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
-	w, err := m.SelectWorker(ctx, t)
+	p, err := m.selectPlacement(ctx, te.Task)
 	cancel()
+	recordSchedulingAttempt(&te.Task, p, err)
+	t := te.Task
 
 	if err != nil {
 		log.Printf("Error selecting worker for task %v: %v", t, err)
+		if existingTask != nil {
+			persisted := *existingTask
+			persisted.Scheduling = t.Scheduling
+			m.saveSchedulingStatus(&persisted)
+		}
 		m.Pending.Enqueue(te)
 		return
 
 	}
+	w := p.worker
 
 	newTask, errResp, err := m.WorkerClient.StartTask(w.Address, te)
 	if err != nil {
 		log.Printf("Error connecting to %v: %v\n", w.ID, err)
+		m.inventory.Release(t.ID)
+		failSchedulingAttempt(&te.Task, fmt.Errorf("dispatch to %s failed: %w", w.ID, err))
 		m.Pending.Enqueue(te)
 		return
 	}
 
 	if errResp != nil {
 		log.Printf("Response error (%d): %s", errResp.HTTPStatusCode, errResp.Message)
+		m.inventory.Release(t.ID)
 		return
 	}
 
 	if newTask != nil {
+		newTask.Scheduling = t.Scheduling
 		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 		if errors.Is(existingErr, store.ErrNotFound) {
 			if err := m.Store.CreateTask(ctx, newTask, w.ID); err != nil {
@@ -1001,7 +1115,24 @@ func NewWithConfig(cfg Config) *Manager {
 		}
 	}
 
-	s := scheduler.NewPipelineScheduler(cfg.SchedulerType, cfg.QueueSortStrategy)
+	latencyStats := scheduler.WithLatencyStatistics(
+		topology.ParseLatencyStatistic(cfg.FilterLatencyStatistic, ""),
+		topology.ParseLatencyStatistic(cfg.ScoreLatencyStatistic, ""),
+	)
+	binpackWeights := scheduler.WithBinpackWeights(cfg.BinpackWeights)
+	s := scheduler.NewPipelineScheduler(cfg.SchedulerType, cfg.QueueSortStrategy, latencyStats, binpackWeights)
+	profiles := map[string]scheduler.Scheduler{scheduler.DefaultProfileName: s}
+	if len(cfg.SchedulerProfiles) > 0 {
+		built, err := scheduler.BuildProfiles(cfg.SchedulerProfiles, cfg.SchedulerType, cfg.QueueSortStrategy, latencyStats, binpackWeights)
+		if err != nil {
+			log.Printf("Manager: ignoring scheduler profiles: %v", err)
+		} else {
+			for name, c := range built {
+				profiles[name] = c
+			}
+			s = profiles[scheduler.DefaultProfileName]
+		}
+	}
 	probeMode := topology.ParseProbeMode(cfg.TopologyProbeMode)
 	probeInterval := cfg.TopologyProbeInterval
 	if probeInterval <= 0 {
@@ -1011,6 +1142,23 @@ func NewWithConfig(cfg Config) *Manager {
 	if probeSampleSize <= 0 {
 		probeSampleSize = 2
 	}
+	probeSamples := cfg.TopologyProbeSamples
+	if probeSamples <= 0 {
+		probeSamples = 5
+	}
+	bandwidthInterval := cfg.TopologyBandwidthProbeInterval
+	if bandwidthInterval <= 0 {
+		bandwidthInterval = 10 * time.Minute
+	}
+	bandwidthBytes := cfg.TopologyBandwidthProbeBytes
+	if bandwidthBytes <= 0 {
+		bandwidthBytes = 8 << 20
+	}
+
+	inventoryInterval := cfg.InventoryRefreshInterval
+	if inventoryInterval <= 0 {
+		inventoryInterval = defaultInventoryInterval
+	}
 
 	wc := cfg.WorkerClient
 	if wc == nil {
@@ -1023,21 +1171,36 @@ func NewWithConfig(cfg Config) *Manager {
 		ID:             id,
 		Pending:        *queue.New(),
 		Scheduler:      s,
+		profiles:       profiles,
 		WorkerClient:   wc,
 		Store:          cfg.Store,
 		AdvertiseAddr:  advertiseAddr,
 		initialWorkers: initialWorkers,
 		electionStop:   make(chan struct{}),
+
+		topologyProbeSamples:        probeSamples,
+		topologyProbeTransport:      topology.ParseProbeTransport(cfg.TopologyProbeTransport, topology.ProbeTransportHTTP),
+		topologyBandwidthProbeBytes: bandwidthBytes,
+		topologyLatencyTTL:          cfg.TopologyLatencyTTL,
+		topologyBandwidthTTL:        cfg.TopologyBandwidthTTL,
+		inventory:                   NewNodeInventory(),
+		inventoryInterval:           inventoryInterval,
 	}
 
 	if m.Store != nil {
 		m.topologyUpdater = topology.NewUpdater(topology.UpdaterConfig{
-			Store:      m.Store,
-			ListNodes:  m.listTopologyNodes,
-			Probe:      m.probeLatency,
-			Mode:       probeMode,
-			Interval:   probeInterval,
-			SampleSize: probeSampleSize,
+			Store:             m.Store,
+			NotFound:          func(err error) bool { return errors.Is(err, store.ErrNotFound) },
+			ListNodes:         m.listTopologyNodes,
+			Probe:             m.probeLatency,
+			Mode:              probeMode,
+			Interval:          probeInterval,
+			SampleSize:        probeSampleSize,
+			LatencyWindow:     cfg.TopologyLatencyWindow,
+			EWMAAlpha:         cfg.TopologyEWMAAlpha,
+			BandwidthProbe:    m.probeBandwidth,
+			BandwidthInterval: bandwidthInterval,
+			FailureThreshold:  cfg.TopologyFailureThreshold,
 		})
 	}
 
@@ -1295,6 +1458,17 @@ func (a *Api) RegisterWorkerHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
+	// Taints are set by operators, not reported by the worker, so a worker
+	// re-registering after a restart keeps them.
+	if existing, err := a.Manager.Store.ListWorkers(ctx); err == nil {
+		for _, e := range existing {
+			if e.ID == worker.ID {
+				worker.Taints = e.Taints
+				break
+			}
+		}
+	}
+
 	if err := a.Manager.Store.RegisterWorker(ctx, worker); err != nil {
 		msg := fmt.Sprintf("Error registering worker %s: %v", worker.ID, err)
 		log.Print(msg)
@@ -1377,6 +1551,14 @@ func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if te.Task.State != task.Completed {
+		if _, err := a.Manager.schedulerFor(te.Task); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: err.Error()})
+			return
+		}
+	}
+
 	if err := a.Manager.AddTask(te); err != nil {
 		msg := fmt.Sprintf("Unable to add task: %v", err)
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -1395,6 +1577,48 @@ func (a *Api) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(a.Manager.GetTasks())
 }
 
+// TaskView is a task together with the worker it is assigned to, as served
+// by GET /tasks/{taskID}.
+type TaskView struct {
+	task.Task
+	WorkerID string `json:"workerId,omitempty"`
+}
+
+// GetTaskHandler handles GET /tasks/{taskID}.
+func (a *Api) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if a.Manager.Store == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusServiceUnavailable, Message: "store not configured"})
+		return
+	}
+
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: fmt.Sprintf("invalid task ID: %v", err)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	t, workerID, err := a.Manager.Store.GetTask(ctx, tID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: status, Message: fmt.Sprintf("error retrieving task %s: %v", tID, err)})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TaskView{Task: *t, WorkerID: workerID})
+}
+
 func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if a.forwardToLeader(w, r) {
 		return
@@ -1525,8 +1749,107 @@ func (a *Api) GetNodesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Partitions are best effort: a missing topology just leaves them out.
+	topo, _ := a.Manager.Store.GetNetworkTopology(ctx)
+
+	nodes := make([]NodeInfo, 0, len(workers))
+	for _, wk := range workers {
+		n := NodeInfo{Worker: wk}
+		if p, ok := topo.GetPartition(wk.ID); ok {
+			n.Partition = &p
+		}
+		nodes = append(nodes, n)
+	}
+
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(workers)
+	json.NewEncoder(w).Encode(nodes)
+}
+
+// NodeInfo is a registered worker as listed by GET /nodes, together with the
+// network partition the topology updater last placed it in.
+type NodeInfo struct {
+	store.Worker
+	Partition *int `json:"partition,omitempty"`
+}
+
+// NodeStats pairs a worker with its most recent stats sample. Error is set
+// instead of Sample when the worker could not be reached.
+type NodeStats struct {
+	ID      string                  `json:"id"`
+	Address string                  `json:"address"`
+	Sample  *workerpkg.StatsSample  `json:"sample,omitempty"`
+	History []workerpkg.StatsSample `json:"history,omitempty"`
+	Error   string                  `json:"error,omitempty"`
+}
+
+// GetNodeStatsHandler handles GET /nodes/stats. It fetches the latest stats
+// sample from every live worker; "history=N" also includes the last N
+// samples. Like /nodes it is served by any manager.
+func (a *Api) GetNodeStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if a.Manager.Store == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusServiceUnavailable, Message: "store not configured"})
+		return
+	}
+
+	historyLen := 0
+	if raw := r.URL.Query().Get("history"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: fmt.Sprintf("invalid history %q", raw)})
+			return
+		}
+		historyLen = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	workers, err := a.Manager.activeWorkers(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode([]NodeStats{})
+		return
+	}
+
+	// A worker that does not answer in time is reported with an error
+	// rather than holding up the others.
+	fetchCtx, fetchCancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer fetchCancel()
+
+	results := make([]NodeStats, len(workers))
+	var wg sync.WaitGroup
+	for i, wk := range workers {
+		wg.Add(1)
+		go func(i int, wk store.Worker) {
+			defer wg.Done()
+			limit := historyLen
+			if limit < 1 {
+				limit = 1
+			}
+			ns := NodeStats{ID: wk.ID, Address: wk.Address}
+			samples, err := a.Manager.WorkerClient.FetchStatsHistory(fetchCtx, wk.Address, limit)
+			switch {
+			case err != nil:
+				ns.Error = err.Error()
+			case len(samples) > 0:
+				latest := samples[len(samples)-1]
+				ns.Sample = &latest
+				if historyLen > 0 {
+					ns.History = samples
+				}
+			}
+			results[i] = ns
+		}(i, wk)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
 }
 
 // ---------------------------------------------------------------------------
@@ -1534,8 +1857,9 @@ func (a *Api) GetNodesHandler(w http.ResponseWriter, r *http.Request) {
 // ---------------------------------------------------------------------------
 
 // DeployAppHandler handles POST /apps — deploys a multi-service application
-// from a manifest definition. Services are deployed in topological order with
-// env-var-based service discovery.
+// from a manifest definition. Services are placed together, then deployed in
+// topological order with env-var-based service discovery. An app no
+// placement can satisfy is refused with 422 and the reason.
 func (a *Api) DeployAppHandler(w http.ResponseWriter, r *http.Request) {
 	if a.forwardToLeader(w, r) {
 		return
@@ -1561,6 +1885,17 @@ func (a *Api) DeployAppHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	result, err := a.Manager.DeployApp(context.Background(), m)
+	if errors.Is(err, ErrUnknownSchedulerProfile) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: fmt.Sprintf("invalid manifest: %v", err)})
+		return
+	}
+	var gangErr *scheduler.GangError
+	if errors.As(err, &gangErr) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusUnprocessableEntity, Message: fmt.Sprintf("deploy failed: %v", err)})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusInternalServerError, Message: fmt.Sprintf("deploy failed: %v", err)})
@@ -1655,6 +1990,8 @@ type DeployResult struct {
 	App      string                       `json:"app"`
 	Status   string                       `json:"status"`
 	Services map[string]DeployServiceInfo `json:"services"`
+	// NetworkCost is the weighted network cost of the app's placement.
+	NetworkCost float64 `json:"network_cost"`
 }
 
 // DeployServiceInfo describes a deployed service's address.
@@ -1664,8 +2001,10 @@ type DeployServiceInfo struct {
 	Address  string `json:"address"` // host:port reachable from LAN
 }
 
-// DeployApp deploys a manifest's services in topological order, injecting
-// service discovery env vars for each dependency that is already running.
+// DeployApp places all of a manifest's services at once, records them all or
+// none and starts them in topological order, injecting service
+// discovery env vars for each dependency that is already running. If any
+// service cannot be started the whole app is rolled back.
 func (m *Manager) DeployApp(ctx context.Context, mf *manifest.Manifest) (*DeployResult, error) {
 	if m.Store == nil {
 		return nil, errors.New("store not configured")
@@ -1687,6 +2026,38 @@ func (m *Manager) DeployApp(ctx context.Context, mf *manifest.Manifest) (*Deploy
 	}
 
 	tasks := manifest.ToTasks(mf, mf.Name)
+	for _, svcName := range order {
+		if t, ok := tasks[svcName]; ok {
+			if _, err := m.schedulerFor(*t); err != nil {
+				return nil, fmt.Errorf("service %s: %w", svcName, err)
+			}
+		}
+	}
+
+	// Place every service together before anything is recorded, so a
+	// deployment either gets a node for each service or none at all.
+	p, err := m.placeApp(ctx, ag, tasks)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Manager %s: app %s placed with network cost %.2f: %v", m.ID, mf.Name, p.gang.NetworkCost, p.gang.Nodes)
+
+	// Record every task as Scheduled on its worker, all or none.
+	records := make([]store.TaskRecord, 0, len(order))
+	for _, svcName := range order {
+		t, ok := tasks[svcName]
+		if !ok {
+			continue
+		}
+		w := p.workers[svcName]
+		var score *float64
+		if s, ok := p.gang.Scores[svcName]; ok {
+			score = &s
+		}
+		t.State = task.Scheduled
+		recordSchedulingAttempt(t, &placement{worker: &w, score: score}, nil)
+		records = append(records, store.TaskRecord{Task: t, WorkerID: w.ID})
+	}
 
 	// Create App record.
 	app := &store.App{
@@ -1694,13 +2065,43 @@ func (m *Manager) DeployApp(ctx context.Context, mf *manifest.Manifest) (*Deploy
 		ServiceTasks: make(map[string]string),
 		Status:       "deploying",
 	}
+	for _, rec := range records {
+		app.ServiceTasks[rec.Task.ServiceID] = rec.Task.ID.String()
+	}
 	appCtx, appCancel := context.WithTimeout(ctx, 5*time.Second)
 	if err := m.Store.CreateApp(appCtx, app); err != nil {
 		appCancel()
+		m.releaseAppPlacement(tasks)
 		return nil, fmt.Errorf("creating app record: %w", err)
 	}
 	appCancel()
 
+	createCtx, createCancel := context.WithTimeout(ctx, 5*time.Second)
+	if err := m.Store.CreateTasks(createCtx, records); err != nil {
+		createCancel()
+		m.releaseAppPlacement(tasks)
+		app.Status = "failed"
+		failCtx, failCancel := context.WithTimeout(ctx, 5*time.Second)
+		_ = m.Store.UpdateApp(failCtx, app)
+		failCancel()
+		return nil, fmt.Errorf("recording app placement: %w", err)
+	}
+	createCancel()
+
+	// Take the bandwidth of every link before starting anything, so a link
+	// the cluster can no longer carry fails the app rather than one service.
+	started := make(map[uuid.UUID]bool)
+	for _, rec := range records {
+		reserveCtx, reserveCancel := context.WithTimeout(ctx, 5*time.Second)
+		err := m.reserveBandwidthForTaskPlacement(reserveCtx, *rec.Task, rec.WorkerID)
+		reserveCancel()
+		if err != nil {
+			err = fmt.Errorf("reserving bandwidth for service %s: %w", rec.Task.ServiceID, err)
+			m.rollbackApp(ctx, app, records, p, started, err)
+			return nil, fmt.Errorf("%w; deployment rolled back", err)
+		}
+	}
+
 	// Track discovered service addresses: serviceName → {host, port}
 	type svcAddr struct {
 		Host string
@@ -1708,48 +2109,38 @@ func (m *Manager) DeployApp(ctx context.Context, mf *manifest.Manifest) (*Deploy
 	}
 	discovery := make(map[string]svcAddr)
 	result := &DeployResult{
-		App:      mf.Name,
-		Status:   "running",
-		Services: make(map[string]DeployServiceInfo),
+		App:         mf.Name,
+		Status:      "running",
+		Services:    make(map[string]DeployServiceInfo),
+		NetworkCost: p.gang.NetworkCost,
 	}
 
-	for _, svcName := range order {
-		t, ok := tasks[svcName]
-		if !ok {
-			continue
-		}
+	for _, rec := range records {
+		t := rec.Task
+		svcName := t.ServiceID
 
 		// Inject discovery env vars from already-deployed dependencies.
 		svc := mf.Services[svcName]
 		for _, dep := range svc.DependsOn {
-			if addr, found := discovery[dep]; found {
-				prefix := manifest.ServiceEnvKey(dep)
+			if addr, found := discovery[dep.Service]; found {
+				prefix := manifest.ServiceEnvKey(dep.Service)
 				t.Env = append(t.Env, fmt.Sprintf("%s_HOST=%s", prefix, addr.Host))
 				t.Env = append(t.Env, fmt.Sprintf("%s_PORT=%s", prefix, addr.Port))
 			}
 		}
 
-		// Persist the task in Pending state.
-		createCtx, createCancel := context.WithTimeout(ctx, 5*time.Second)
-		if err := m.Store.CreateTask(createCtx, t, ""); err != nil {
-			createCancel()
-			result.Status = "partial"
-			log.Printf("Manager %s: failed to create task for service %s: %v", m.ID, svcName, err)
-			break
+		if err := m.startAppTask(ctx, t, p.workers[svcName]); err != nil {
+			err = fmt.Errorf("starting service %s: %w", svcName, err)
+			m.rollbackApp(ctx, app, records, p, started, err)
+			return nil, fmt.Errorf("%w; deployment rolled back", err)
 		}
-		createCancel()
-
-		// Record in app.
-		app.ServiceTasks[svcName] = t.ID.String()
-		updateCtx, updateCancel := context.WithTimeout(ctx, 5*time.Second)
-		_ = m.Store.UpdateApp(updateCtx, app)
-		updateCancel()
+		started[t.ID] = true
 
 		// Wait for the task to reach Running state.
 		if err := m.waitForTaskRunning(ctx, t.ID, 120*time.Second); err != nil {
 			log.Printf("Manager %s: service %s did not reach Running: %v", m.ID, svcName, err)
 			result.Status = "partial"
-			result.Services[svcName] = DeployServiceInfo{TaskID: t.ID.String(), WorkerID: "", Address: "pending"}
+			result.Services[svcName] = DeployServiceInfo{TaskID: t.ID.String(), WorkerID: rec.WorkerID, Address: "pending"}
 			continue
 		}
 
@@ -1930,16 +2321,32 @@ func (a *Api) initRouter() {
 		r.Post("/", a.StartTaskHandler)
 		r.Get("/", a.GetTasksHandler)
 		r.Route("/{taskID}", func(r chi.Router) {
+			r.Get("/", a.GetTaskHandler)
 			r.Delete("/", a.StopTaskHandler)
 		})
 	})
 	a.Router.Route("/workers", func(r chi.Router) {
 		r.Post("/", a.RegisterWorkerHandler)
 		r.Route("/{workerID}", func(r chi.Router) {
+			r.Delete("/", a.DeregisterWorkerHandler)
 			r.Put("/heartbeat", a.HeartbeatHandler)
+			r.Post("/drain", a.DrainWorkerHandler)
+			r.Post("/taints", a.TaintWorkerHandler)
 		})
 	})
 	a.Router.Get("/nodes", a.GetNodesHandler)
+	a.Router.Get("/nodes/stats", a.GetNodeStatsHandler)
+	a.Router.Get("/reservations", a.GetReservationsHandler)
+	a.Router.Post("/schedule/explain", a.ExplainScheduleHandler)
+	a.Router.Route("/topology", func(r chi.Router) {
+		r.Get("/", a.GetTopologyHandler)
+		r.Route("/overrides", func(r chi.Router) {
+			r.Get("/", a.GetTopologyOverridesHandler)
+			r.Put("/nodes/{nodeID}", a.SetNodePlacementHandler)
+			r.Put("/links/{from}/{to}", a.SetLinkOverrideHandler)
+			r.Delete("/links/{from}/{to}", a.DeleteLinkOverrideHandler)
+		})
+	})
 	a.Router.Route("/apps", func(r chi.Router) {
 		r.Post("/", a.DeployAppHandler)
 		r.Get("/", a.ListAppsHandler)
@@ -2109,6 +2516,12 @@ func (m *Manager) restartTask(t *task.Task) {
 		return
 	}
 
+	if assignedWorker.Draining {
+		log.Printf("Assigned worker %s for task %s is draining; rescheduling elsewhere", workerID, t.ID)
+		m.resetTaskToPending(*t)
+		return
+	}
+
 	t.State = task.Scheduled
 	t.RestartCount++
 
@@ -2118,6 +2531,14 @@ func (m *Manager) restartTask(t *task.Task) {
 	}
 	cancel()
 
+	// The failure released the task's bandwidth; take it again for the
+	// restarted instance on the same worker.
+	reserveCtx, reserveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := m.reserveBandwidthForTaskPlacement(reserveCtx, *t, workerID); err != nil {
+		log.Printf("Manager %s: restarting task %s without a bandwidth reservation: %v", m.ID, t.ID, err)
+	}
+	reserveCancel()
+
 	m.dispatchTaskToWorker(*t, assignedWorker)
 
 }
@@ -2169,6 +2590,8 @@ func (m *Manager) stopTask(worker string, taskID string) {
 			log.Printf("Error persisting stop for task %s: %v", taskID, err)
 		}
 		cancel()
+
+		m.releaseBandwidthForTask(t.ID)
 	}
 
 	log.Printf("Task %s has been scheduled to be stopped", taskID)
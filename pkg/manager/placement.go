@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/store"
+	"github.com/aditip149209/okube/pkg/task"
+)
+
+// placement is the outcome of a successful scheduling decision.
+type placement struct {
+	worker *store.Worker
+	// score is the pipeline score the worker won with; lower is better.
+	score *float64
+}
+
+// recordSchedulingAttempt counts one scheduling decision on t and records
+// either the chosen node and score or the reason no node was chosen.
+func recordSchedulingAttempt(t *task.Task, p *placement, err error) {
+	status := task.SchedulingStatus{}
+	if t.Scheduling != nil {
+		status = *t.Scheduling
+	}
+	status.Attempts++
+	status.LastAttempt = time.Now().UTC()
+	status.LastReason = ""
+	status.Node = ""
+	status.Score = nil
+	if err != nil {
+		status.LastReason = err.Error()
+	} else if p != nil && p.worker != nil {
+		status.Node = p.worker.ID
+		status.Score = p.score
+	}
+	t.Scheduling = &status
+}
+
+// failSchedulingAttempt marks the latest attempt as failed after a node was
+// chosen, e.g. because the bandwidth could not be reserved or the worker
+// refused the task.
+func failSchedulingAttempt(t *task.Task, err error) {
+	if t.Scheduling == nil {
+		recordSchedulingAttempt(t, nil, err)
+		return
+	}
+	status := *t.Scheduling
+	status.LastReason = err.Error()
+	status.Node = ""
+	status.Score = nil
+	t.Scheduling = &status
+}
+
+// saveSchedulingStatus persists t's scheduling status while it stays
+// unplaced. The state key is left alone: a Pending state write would wake
+// the pending watch and retry the task at once, without end.
+func (m *Manager) saveSchedulingStatus(t *task.Task) {
+	if m.Store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Store.SaveTaskScheduling(ctx, t); err != nil {
+		log.Printf("Manager %s: failed to record scheduling attempt for task %s: %v", m.ID, t.ID, err)
+	}
+}
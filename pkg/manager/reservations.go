@@ -0,0 +1,291 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/store"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/google/uuid"
+)
+
+// reservationReconcileInterval is how often the leader drops ledger entries
+// whose task no longer holds its placement.
+const reservationReconcileInterval = 60 * time.Second
+
+// ReservationsView is the bandwidth ledger as served by GET /reservations.
+type ReservationsView struct {
+	Reservations []store.BandwidthReservation `json:"reservations"`
+	Links        []ReservedLinkView           `json:"links"`
+}
+
+// ReservedLinkView is the total bandwidth reserved on one directed link.
+type ReservedLinkView struct {
+	From         string  `json:"from"`
+	To           string  `json:"to"`
+	ReservedMbps float64 `json:"reservedMbps"`
+	Tasks        int     `json:"tasks"`
+}
+
+// reservedBandwidth sums the ledger per directed link.
+func reservedBandwidth(reservations []store.BandwidthReservation) map[string]map[string]float64 {
+	totals := make(map[string]map[string]float64)
+	for _, r := range reservations {
+		if _, ok := totals[r.FromNode]; !ok {
+			totals[r.FromNode] = make(map[string]float64)
+		}
+		totals[r.FromNode][r.ToNode] += r.Mbps
+	}
+	return totals
+}
+
+// reserveBandwidthForTaskPlacement records in the ledger the bandwidth the
+// task needs towards each placed dependency from candidateNodeID. Any entries
+// the task already held are replaced. It fails without recording anything
+// when a link lacks the bandwidth.
+func (m *Manager) reserveBandwidthForTaskPlacement(ctx context.Context, t task.Task, candidateNodeID string) error {
+	if m.Store == nil || t.AppID == "" {
+		return nil
+	}
+
+	// Drop the task's own earlier entries first so they are not counted
+	// against it when it is placed again.
+	if _, err := m.Store.DeleteBandwidthReservations(ctx, t.ID.String()); err != nil {
+		return err
+	}
+
+	filterCtx := m.buildFilterContext(ctx, t)
+	if filterCtx == nil || filterCtx.AppGroup == nil || filterCtx.NetworkTopology == nil {
+		return nil
+	}
+
+	serviceID := taskServiceID(&t)
+	if serviceID == "" {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	reservations := make([]store.BandwidthReservation, 0)
+	for _, edge := range filterCtx.AppGroup.GetDependencies(serviceID) {
+		if edge.MinBandwidth == nil || *edge.MinBandwidth <= 0 {
+			continue
+		}
+
+		depNodeID, ok := filterCtx.DependencyNodeByService[edge.To]
+		if !ok || depNodeID == "" || depNodeID == candidateNodeID {
+			continue
+		}
+
+		// The scheduling view already has the ledger applied, so this only
+		// checks (and tentatively debits) what is left on the link.
+		if !filterCtx.NetworkTopology.ReserveBandwidth(candidateNodeID, depNodeID, *edge.MinBandwidth) {
+			// A soft minimum is best effort: place the task anyway, without
+			// holding bandwidth the link does not have.
+			if edge.Soft {
+				continue
+			}
+			return fmt.Errorf("insufficient available bandwidth from %s to %s", candidateNodeID, depNodeID)
+		}
+
+		reservations = append(reservations, store.BandwidthReservation{
+			TaskID:    t.ID.String(),
+			AppID:     t.AppID,
+			From:      serviceID,
+			To:        edge.To,
+			FromNode:  candidateNodeID,
+			ToNode:    depNodeID,
+			Mbps:      *edge.MinBandwidth,
+			CreatedAt: now,
+		})
+	}
+
+	if len(reservations) == 0 {
+		return nil
+	}
+	return m.Store.SaveBandwidthReservations(ctx, t.ID.String(), reservations)
+}
+
+// releaseBandwidthForTask removes every ledger entry held by a task.
+func (m *Manager) releaseBandwidthForTask(taskID uuid.UUID) {
+	if m.Store == nil || taskID == uuid.Nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	released, err := m.Store.DeleteBandwidthReservations(ctx, taskID.String())
+	if err != nil {
+		log.Printf("Manager %s: failed to release bandwidth for task %s: %v", m.ID, taskID, err)
+		return
+	}
+	if released > 0 {
+		log.Printf("Manager %s: released %d bandwidth reservation(s) for task %s", m.ID, released, taskID)
+	}
+}
+
+// reconcileReservations drops ledger entries whose task is gone, is no
+// longer Scheduled or Running, or has moved off the node the entry was
+// made for. Entries whose dependency no longer runs on the entry's ToNode
+// are made again towards where it runs now. It catches releases missed
+// while no manager was leader.
+func (m *Manager) reconcileReservations(ctx context.Context) error {
+	reservations, err := m.Store.ListBandwidthReservations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		return nil
+	}
+
+	records, err := m.Store.ListTasks(ctx)
+	if err != nil {
+		return err
+	}
+	holding := make(map[string]store.TaskRecord, len(records))
+	// serviceNodes maps app ID and service to the nodes it runs on.
+	serviceNodes := make(map[string]map[string]map[string]bool)
+	for _, rec := range records {
+		if rec.Task == nil {
+			continue
+		}
+		if rec.Task.State != task.Running && rec.Task.State != task.Scheduled {
+			continue
+		}
+		holding[rec.Task.ID.String()] = rec
+		serviceID := taskServiceID(rec.Task)
+		if rec.Task.AppID == "" || serviceID == "" || rec.WorkerID == "" {
+			continue
+		}
+		if serviceNodes[rec.Task.AppID] == nil {
+			serviceNodes[rec.Task.AppID] = make(map[string]map[string]bool)
+		}
+		if serviceNodes[rec.Task.AppID][serviceID] == nil {
+			serviceNodes[rec.Task.AppID][serviceID] = make(map[string]bool)
+		}
+		serviceNodes[rec.Task.AppID][serviceID][rec.WorkerID] = true
+	}
+
+	stale := make(map[string]bool)
+	// moved maps a task whose dependency left the entry's ToNode to the
+	// node the task runs on.
+	moved := make(map[string]string)
+	for _, r := range reservations {
+		rec, ok := holding[r.TaskID]
+		if !ok || (rec.WorkerID != "" && rec.WorkerID != r.FromNode) {
+			stale[r.TaskID] = true
+			continue
+		}
+		if !serviceNodes[r.AppID][r.To][r.ToNode] {
+			moved[r.TaskID] = r.FromNode
+		}
+	}
+
+	for taskID := range stale {
+		released, err := m.Store.DeleteBandwidthReservations(ctx, taskID)
+		if err != nil {
+			log.Printf("Manager %s: failed to release orphaned bandwidth for task %s: %v", m.ID, taskID, err)
+			continue
+		}
+		log.Printf("Manager %s: reconciled %d orphaned bandwidth reservation(s) for task %s", m.ID, released, taskID)
+	}
+
+	// Reserving again replaces the task's entries; when the new links lack
+	// the bandwidth the old entries are dropped all the same.
+	for taskID, nodeID := range moved {
+		if stale[taskID] {
+			continue
+		}
+		if err := m.reserveBandwidthForTaskPlacement(ctx, *holding[taskID].Task, nodeID); err != nil {
+			log.Printf("Manager %s: dependencies of task %s moved; bandwidth not reserved again: %v", m.ID, taskID, err)
+			continue
+		}
+		log.Printf("Manager %s: dependencies of task %s moved; bandwidth reserved again", m.ID, taskID)
+	}
+	return nil
+}
+
+// ReconcileReservations periodically reconciles the bandwidth ledger against
+// the tasks that are running. Only the leader reconciles.
+func (m *Manager) ReconcileReservations() {
+	for {
+		if !m.IsLeader() || m.Store == nil {
+			time.Sleep(reservationReconcileInterval)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := m.reconcileReservations(ctx); err != nil {
+			log.Printf("Manager %s: bandwidth reservation reconcile failed: %v", m.ID, err)
+		}
+		cancel()
+		time.Sleep(reservationReconcileInterval)
+	}
+}
+
+// GetReservationsHandler handles GET /reservations. It lists the bandwidth
+// ledger and the total reserved per link. Like /nodes it is served by any
+// manager.
+func (a *Api) GetReservationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if a.Manager.Store == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusServiceUnavailable, Message: "store not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	reservations, err := a.Manager.Store.ListBandwidthReservations(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusInternalServerError, Message: fmt.Sprintf("error listing bandwidth reservations: %v", err)})
+		return
+	}
+
+	if appID := r.URL.Query().Get("app"); appID != "" {
+		filtered := reservations[:0]
+		for _, res := range reservations {
+			if res.AppID == appID {
+				filtered = append(filtered, res)
+			}
+		}
+		reservations = filtered
+	}
+
+	sort.Slice(reservations, func(i, j int) bool {
+		if reservations[i].TaskID != reservations[j].TaskID {
+			return reservations[i].TaskID < reservations[j].TaskID
+		}
+		return reservations[i].To < reservations[j].To
+	})
+
+	view := ReservationsView{Reservations: reservations, Links: []ReservedLinkView{}}
+	tasks := make(map[string]map[string]int)
+	for _, res := range reservations {
+		if _, ok := tasks[res.FromNode]; !ok {
+			tasks[res.FromNode] = make(map[string]int)
+		}
+		tasks[res.FromNode][res.ToNode]++
+	}
+	for from, peers := range reservedBandwidth(reservations) {
+		for to, mbps := range peers {
+			view.Links = append(view.Links, ReservedLinkView{From: from, To: to, ReservedMbps: mbps, Tasks: tasks[from][to]})
+		}
+	}
+	sort.Slice(view.Links, func(i, j int) bool {
+		if view.Links[i].From != view.Links[j].From {
+			return view.Links[i].From < view.Links[j].From
+		}
+		return view.Links[i].To < view.Links[j].To
+	})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(view)
+}
@@ -0,0 +1,175 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/store"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/go-chi/chi"
+)
+
+// TaintRequest is the body of POST /workers/{workerID}/taints. Added taints
+// replace any taint with the same key and effect; a removed taint without
+// an effect removes every taint with its key.
+type TaintRequest struct {
+	Add    []task.Taint `json:"add,omitempty"`
+	Remove []task.Taint `json:"remove,omitempty"`
+}
+
+// TaintResult is a worker's taints after an update and the tasks a NoExecute
+// taint evicted from it.
+type TaintResult struct {
+	WorkerID string       `json:"worker_id"`
+	Taints   []task.Taint `json:"taints"`
+	Evicted  []string     `json:"evicted"`
+}
+
+// updateTaints applies req to taints and returns the new list.
+func updateTaints(taints []task.Taint, req TaintRequest) []task.Taint {
+	updated := make([]task.Taint, 0, len(taints)+len(req.Add))
+	for _, t := range taints {
+		removed := false
+		for _, r := range req.Remove {
+			if r.Key == t.Key && (r.Effect == "" || r.Effect == t.Effect) {
+				removed = true
+				break
+			}
+		}
+		for _, a := range req.Add {
+			if a.Key == t.Key && a.Effect == t.Effect {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			updated = append(updated, t)
+		}
+	}
+	return append(updated, req.Add...)
+}
+
+// evictionTaint returns the first NoExecute taint t does not tolerate.
+func evictionTaint(t *task.Task, taints []task.Taint) (task.Taint, bool) {
+	for _, taint := range taints {
+		if taint.Effect == task.TaintNoExecute && !t.ToleratesTaint(taint) {
+			return taint, true
+		}
+	}
+	return task.Taint{}, false
+}
+
+// TaintWorker updates a worker's taints. Tasks on the worker that do not
+// tolerate one of its NoExecute taints are stopped there and reset to
+// Pending, so the scheduler places them on another worker.
+func (m *Manager) TaintWorker(ctx context.Context, workerID string, req TaintRequest) (*TaintResult, error) {
+	if !m.IsLeader() {
+		return nil, ErrNotLeader
+	}
+	if m.Store == nil {
+		return nil, errors.New("store not configured")
+	}
+
+	workers, err := m.Store.ListWorkers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing workers: %w", err)
+	}
+	var worker *store.Worker
+	for i := range workers {
+		if workers[i].ID == workerID {
+			worker = &workers[i]
+			break
+		}
+	}
+	if worker == nil {
+		return nil, store.ErrNotFound
+	}
+
+	taints := updateTaints(worker.Taints, req)
+	if err := m.Store.SetWorkerTaints(ctx, workerID, taints); err != nil {
+		return nil, err
+	}
+
+	records, err := m.Store.ListTasks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing tasks for eviction: %w", err)
+	}
+
+	result := &TaintResult{WorkerID: workerID, Taints: taints, Evicted: []string{}}
+	for _, rec := range records {
+		if rec.Task == nil || rec.WorkerID != workerID {
+			continue
+		}
+		if rec.Task.State != task.Running && rec.Task.State != task.Scheduled {
+			continue
+		}
+		taint, evict := evictionTaint(rec.Task, taints)
+		if !evict {
+			continue
+		}
+
+		if err := m.WorkerClient.StopTask(worker.Address, rec.Task.ID.String()); err != nil {
+			log.Printf("Manager %s: stopping evicted task %s on worker %s: %v", m.ID, rec.Task.ID, workerID, err)
+		}
+		m.resetTaskToPending(*rec.Task)
+		result.Evicted = append(result.Evicted, rec.Task.ID.String())
+		log.Printf("Manager %s: evicted task %s from worker %s: taint %s not tolerated", m.ID, rec.Task.ID, workerID, taint)
+	}
+
+	log.Printf("Manager %s: worker %s now has %d taint(s); %d task(s) evicted", m.ID, workerID, len(taints), len(result.Evicted))
+	return result, nil
+}
+
+// TaintWorkerHandler handles POST /workers/{workerID}/taints.
+func (a *Api) TaintWorkerHandler(w http.ResponseWriter, r *http.Request) {
+	if a.forwardToLeader(w, r) {
+		return
+	}
+
+	workerID := chi.URLParam(r, "workerID")
+	if workerID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: "worker id is required"})
+		return
+	}
+
+	var req TaintRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		msg := fmt.Sprintf("Error unmarshalling body: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: msg})
+		return
+	}
+	for _, t := range req.Add {
+		if err := t.Validate(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: err.Error()})
+			return
+		}
+	}
+	for _, t := range req.Remove {
+		if t.Key == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: "taint to remove without a key"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	result, err := a.Manager.TaintWorker(ctx, workerID, req)
+	if err != nil {
+		writeWorkerLifecycleError(w, workerID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
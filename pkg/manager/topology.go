@@ -0,0 +1,401 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/store"
+	"github.com/aditip149209/okube/pkg/topology"
+	"github.com/go-chi/chi"
+)
+
+// TopologyView is a flattened, display-oriented form of the network
+// topology returned by GET /topology.
+type TopologyView struct {
+	Version int64              `json:"version"`
+	Nodes   []TopologyNodeView `json:"nodes"`
+	Links   []TopologyLinkView `json:"links"`
+}
+
+// TopologyNodeView describes one node and where it sits in the cluster.
+type TopologyNodeView struct {
+	ID      string `json:"id"`
+	Zone    string `json:"zone,omitempty"`
+	Region  string `json:"region,omitempty"`
+	Live    bool   `json:"live"`
+	Address string `json:"address,omitempty"`
+	// Partition is the connected component the node was last seen in.
+	Partition *int `json:"partition,omitempty"`
+}
+
+// TopologyLinkView describes the directed link From -> To. Nil values are
+// unknown. LatencyEstimated is set when LatencyMs comes from network
+// coordinates rather than a probe, and Stale when the measurement is older
+// than the scheduler's TTL (the scheduler ignores stale values).
+type TopologyLinkView struct {
+	From                string     `json:"from"`
+	To                  string     `json:"to"`
+	LatencyMs           *float64   `json:"latencyMs,omitempty"`
+	LatencyEstimated    bool       `json:"latencyEstimated,omitempty"`
+	LatencyP50Ms        *float64   `json:"latencyP50Ms,omitempty"`
+	LatencyP95Ms        *float64   `json:"latencyP95Ms,omitempty"`
+	JitterMs            *float64   `json:"jitterMs,omitempty"`
+	LossRate            *float64   `json:"lossRate,omitempty"`
+	LatencyMeasuredAt   *time.Time `json:"latencyMeasuredAt,omitempty"`
+	LatencyStale        bool       `json:"latencyStale,omitempty"`
+	BandwidthMbps       *float64   `json:"bandwidthMbps,omitempty"`
+	AvailableMbps       *float64   `json:"availableMbps,omitempty"`
+	BandwidthMeasuredAt *time.Time `json:"bandwidthMeasuredAt,omitempty"`
+	BandwidthStale      bool       `json:"bandwidthStale,omitempty"`
+	// LatencyPinned, BandwidthCapped and Forbidden reflect administrator
+	// overrides merged over the probed values.
+	LatencyPinned   bool `json:"latencyPinned,omitempty"`
+	BandwidthCapped bool `json:"bandwidthCapped,omitempty"`
+	Forbidden       bool `json:"forbidden,omitempty"`
+}
+
+// schedulingTopology returns the topology as the scheduler should see it:
+// links older than their TTL are dropped so they count as unknown, the
+// administrator overrides are merged on top, and available bandwidth is
+// capacity minus the reservation ledger. The result is a copy and must
+// never be saved back to the store.
+func (m *Manager) schedulingTopology(ctx context.Context) (*topology.NetworkTopology, error) {
+	topo, err := m.Store.GetNetworkTopology(ctx)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+		// Declared zones and links still apply before the first probe.
+		topo = &topology.NetworkTopology{}
+	}
+
+	overrides, err := m.Store.GetTopologyOverrides(ctx)
+	if err != nil {
+		log.Printf("Manager %s: failed to load topology overrides: %v", m.ID, err)
+		overrides = nil
+	}
+
+	// Available bandwidth is derived from the ledger rather than trusted
+	// from the snapshot, which probe rounds rewrite.
+	reservations, err := m.Store.ListBandwidthReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	view := topo.
+		WithoutStale(m.topologyLatencyTTL, m.topologyBandwidthTTL, time.Now().UTC()).
+		ApplyOverrides(overrides)
+	view.ApplyReservations(reservedBandwidth(reservations))
+	return view, nil
+}
+
+// TopologyView builds the view of the stored topology for every node that
+// appears in it or is currently live.
+func (m *Manager) TopologyView(ctx context.Context) (*TopologyView, error) {
+	if m.Store == nil {
+		return nil, errors.New("store not configured")
+	}
+
+	topo, err := m.Store.GetNetworkTopology(ctx)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if topo == nil {
+		topo = &topology.NetworkTopology{}
+	}
+
+	overrides, err := m.Store.GetTopologyOverrides(ctx)
+	if err != nil {
+		return nil, err
+	}
+	topo = topo.ApplyOverrides(overrides)
+
+	reservations, err := m.Store.ListBandwidthReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	topo.ApplyReservations(reservedBandwidth(reservations))
+
+	live := make(map[string]store.Worker)
+	if workers, err := m.activeWorkers(ctx); err == nil {
+		for _, w := range workers {
+			live[w.ID] = w
+		}
+	}
+
+	ids := topo.NodeIDs()
+	for id := range live {
+		ids[id] = true
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	view := &TopologyView{
+		Version: topo.Version,
+		Nodes:   make([]TopologyNodeView, 0, len(sorted)),
+		Links:   make([]TopologyLinkView, 0, len(sorted)*len(sorted)),
+	}
+	for _, id := range sorted {
+		n := TopologyNodeView{ID: id}
+		n.Zone, _ = topo.GetZone(id)
+		n.Region, _ = topo.GetRegion(id)
+		if p, ok := topo.GetPartition(id); ok {
+			n.Partition = &p
+		}
+		if w, ok := live[id]; ok {
+			n.Live = true
+			n.Address = w.Address
+		}
+		view.Nodes = append(view.Nodes, n)
+	}
+
+	now := time.Now().UTC()
+	for _, from := range sorted {
+		for _, to := range sorted {
+			if from == to {
+				continue
+			}
+			if link, ok := m.topologyLinkView(topo, overrides, from, to, now); ok {
+				view.Links = append(view.Links, link)
+			}
+		}
+	}
+
+	return view, nil
+}
+
+func (m *Manager) topologyLinkView(topo *topology.NetworkTopology, overrides *topology.Overrides, from, to string, now time.Time) (TopologyLinkView, bool) {
+	link := TopologyLinkView{From: from, To: to}
+	known := false
+
+	if override, ok := overrides.GetLink(from, to); ok {
+		link.LatencyPinned = override.LatencyMs != nil
+		link.BandwidthCapped = override.BandwidthCapMbps != nil
+		link.Forbidden = override.Forbidden
+		known = true
+	}
+
+	if latency, estimated, ok := topo.GetLatencyOrEstimate(from, to, topology.LatencyEWMA); ok {
+		link.LatencyMs = floatPtr(latency)
+		link.LatencyEstimated = estimated
+		known = true
+	}
+	if stats, ok := topo.GetLinkLatency(from, to); ok {
+		if _, ok := stats.Value(topology.LatencyEWMA); ok {
+			link.LatencyP50Ms = floatPtr(stats.P50)
+			link.LatencyP95Ms = floatPtr(stats.P95)
+			link.JitterMs = floatPtr(stats.Jitter)
+		}
+		link.LossRate = floatPtr(stats.LossRate)
+		known = true
+	}
+	if at, ok := topo.GetLatencyMeasuredAt(from, to); ok {
+		link.LatencyMeasuredAt = &at
+		link.LatencyStale = m.topologyLatencyTTL > 0 && now.Sub(at) > m.topologyLatencyTTL
+	}
+
+	if bw, ok := topo.GetBandwidth(from, to); ok {
+		link.BandwidthMbps = floatPtr(bw)
+		known = true
+	}
+	if avail, ok := topo.GetAvailableBandwidth(from, to); ok {
+		link.AvailableMbps = floatPtr(avail)
+		known = true
+	}
+	if at, ok := topo.GetBandwidthMeasuredAt(from, to); ok {
+		link.BandwidthMeasuredAt = &at
+		link.BandwidthStale = m.topologyBandwidthTTL > 0 && now.Sub(at) > m.topologyBandwidthTTL
+	}
+
+	return link, known
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// GetTopologyHandler handles GET /topology. It returns the TopologyView, or
+// the stored NetworkTopology unchanged when "raw=true" is given. Like /nodes
+// it is served by any manager.
+func (a *Api) GetTopologyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if a.Manager.Store == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusServiceUnavailable, Message: "store not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if r.URL.Query().Get("raw") == "true" {
+		topo, err := a.Manager.Store.GetNetworkTopology(ctx)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusInternalServerError, Message: fmt.Sprintf("Error loading topology: %v", err)})
+			return
+		}
+		if topo == nil {
+			topo = &topology.NetworkTopology{}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(topo)
+		return
+	}
+
+	view, err := a.Manager.TopologyView(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusInternalServerError, Message: fmt.Sprintf("Error loading topology: %v", err)})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(view)
+}
+
+// SetNodePlacementRequest is the body of PUT /topology/overrides/nodes/{nodeID}.
+type SetNodePlacementRequest struct {
+	Zone   string `json:"zone"`
+	Region string `json:"region"`
+}
+
+// GetTopologyOverridesHandler handles GET /topology/overrides.
+func (a *Api) GetTopologyOverridesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if a.Manager.Store == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusServiceUnavailable, Message: "store not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	overrides, err := a.Manager.Store.GetTopologyOverrides(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusInternalServerError, Message: fmt.Sprintf("Error loading topology overrides: %v", err)})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(overrides)
+}
+
+// SetNodePlacementHandler handles PUT /topology/overrides/nodes/{nodeID}.
+// Empty zone or region values clear the declaration.
+func (a *Api) SetNodePlacementHandler(w http.ResponseWriter, r *http.Request) {
+	if a.forwardToLeader(w, r) {
+		return
+	}
+
+	nodeID := chi.URLParam(r, "nodeID")
+	var req SetNodePlacementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: fmt.Sprintf("Error unmarshalling body: %v", err)})
+		return
+	}
+
+	a.updateTopologyOverrides(w, r, func(o *topology.Overrides) {
+		o.SetNodePlacement(nodeID, req.Zone, req.Region)
+	})
+}
+
+// SetLinkOverrideHandler handles PUT /topology/overrides/links/{from}/{to}.
+// The body replaces the link's override; "symmetric=true" applies it to the
+// reverse direction as well.
+func (a *Api) SetLinkOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	if a.forwardToLeader(w, r) {
+		return
+	}
+
+	from, to := chi.URLParam(r, "from"), chi.URLParam(r, "to")
+	if from == to {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: "a link needs two distinct nodes"})
+		return
+	}
+
+	var link topology.LinkOverride
+	if err := json.NewDecoder(r.Body).Decode(&link); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: fmt.Sprintf("Error unmarshalling body: %v", err)})
+		return
+	}
+	if (link.LatencyMs != nil && *link.LatencyMs < 0) || (link.BandwidthCapMbps != nil && *link.BandwidthCapMbps < 0) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadRequest, Message: "latency and bandwidth cap must not be negative"})
+		return
+	}
+
+	symmetric := r.URL.Query().Get("symmetric") == "true"
+	a.updateTopologyOverrides(w, r, func(o *topology.Overrides) {
+		o.SetLink(from, to, link)
+		if symmetric {
+			o.SetLink(to, from, link)
+		}
+	})
+}
+
+// DeleteLinkOverrideHandler handles DELETE /topology/overrides/links/{from}/{to}.
+func (a *Api) DeleteLinkOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	if a.forwardToLeader(w, r) {
+		return
+	}
+
+	from, to := chi.URLParam(r, "from"), chi.URLParam(r, "to")
+	symmetric := r.URL.Query().Get("symmetric") == "true"
+	a.updateTopologyOverrides(w, r, func(o *topology.Overrides) {
+		o.DeleteLink(from, to)
+		if symmetric {
+			o.DeleteLink(to, from)
+		}
+	})
+}
+
+// updateTopologyOverrides loads the overrides, applies mutate, saves them and
+// writes the result.
+func (a *Api) updateTopologyOverrides(w http.ResponseWriter, r *http.Request, mutate func(o *topology.Overrides)) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if a.Manager.Store == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusServiceUnavailable, Message: "store not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	overrides, err := a.Manager.Store.GetTopologyOverrides(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusInternalServerError, Message: fmt.Sprintf("Error loading topology overrides: %v", err)})
+		return
+	}
+
+	mutate(overrides)
+
+	if err := a.Manager.Store.SaveTopologyOverrides(ctx, overrides); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusInternalServerError, Message: fmt.Sprintf("Error saving topology overrides: %v", err)})
+		return
+	}
+
+	log.Printf("Manager %s: topology overrides updated", a.Manager.ID)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(overrides)
+}
@@ -2,11 +2,14 @@ package manager
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/aditip149209/okube/pkg/task"
+	"github.com/aditip149209/okube/pkg/topology"
 	workerpkg "github.com/aditip149209/okube/pkg/worker"
 )
 
@@ -14,6 +17,10 @@ type WorkerCommunicator interface {
 	FetchTasks(worker string) ([]*task.Task, error)
 	StartTask(worker string, event task.TaskEvent) (*task.Task, *workerpkg.ErrResponse, error)
 	StopTask(worker string, taskID string) error
+	FetchStats(ctx context.Context, worker string) (*workerpkg.Stats, error)
+	FetchStatsHistory(ctx context.Context, worker string, limit int) ([]workerpkg.StatsSample, error)
+	ProbeLatency(ctx context.Context, worker string, target string, samples int, transport topology.ProbeTransport) (*workerpkg.LatencyProbeResult, error)
+	ProbeBandwidth(ctx context.Context, worker string, target string, bytes int64) (*workerpkg.BandwidthProbeResult, error)
 }
 
 type HTTPWorkerClient struct {
@@ -97,3 +104,116 @@ func (h *HTTPWorkerClient) StopTask(worker string, taskID string) error {
 
 	return nil
 }
+
+// FetchStats returns the worker's current full stats snapshot.
+func (h *HTTPWorkerClient) FetchStats(ctx context.Context, worker string) (*workerpkg.Stats, error) {
+	statsURL := fmt.Sprintf("http://%s/stats", worker)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, statsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, statsURL)
+	}
+
+	var stats workerpkg.Stats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (h *HTTPWorkerClient) FetchStatsHistory(ctx context.Context, worker string, limit int) ([]workerpkg.StatsSample, error) {
+	url := fmt.Sprintf("http://%s/stats/history?limit=%d", worker, limit)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+
+	var samples []workerpkg.StatsSample
+	if err := json.NewDecoder(resp.Body).Decode(&samples); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// ProbeLatency asks worker to measure the round-trip time to target, another
+// worker's address, over the given transport.
+func (h *HTTPWorkerClient) ProbeLatency(ctx context.Context, worker string, target string, samples int, transport topology.ProbeTransport) (*workerpkg.LatencyProbeResult, error) {
+	probeURL := fmt.Sprintf("http://%s/probe/latency?target=%s&samples=%d&transport=%s", worker, url.QueryEscape(target), samples, transport)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		respErr := workerpkg.ErrResponse{}
+		if err := decoder.Decode(&respErr); err != nil {
+			return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, probeURL)
+		}
+		return nil, fmt.Errorf("probe from %s to %s failed: %s", worker, target, respErr.Message)
+	}
+
+	var result workerpkg.LatencyProbeResult
+	if err := decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ProbeBandwidth asks worker to time a bulk transfer of bytes to target,
+// another worker's address.
+func (h *HTTPWorkerClient) ProbeBandwidth(ctx context.Context, worker string, target string, bytes int64) (*workerpkg.BandwidthProbeResult, error) {
+	probeURL := fmt.Sprintf("http://%s/probe/bandwidth?target=%s&bytes=%d", worker, url.QueryEscape(target), bytes)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		respErr := workerpkg.ErrResponse{}
+		if err := decoder.Decode(&respErr); err != nil {
+			return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, probeURL)
+		}
+		return nil, fmt.Errorf("bandwidth probe from %s to %s failed: %s", worker, target, respErr.Message)
+	}
+
+	var result workerpkg.BandwidthProbeResult
+	if err := decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
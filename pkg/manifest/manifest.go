@@ -1,7 +1,9 @@
 package manifest
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"strings"
 
@@ -19,16 +21,122 @@ type ServiceResources struct {
 	CPU    float64 `yaml:"cpu" json:"cpu"`
 }
 
+// Dependency is one entry of a service's dependsOn list. It is written
+// either as a plain service name or as a mapping that adds network
+// requirements on the link to that service:
+//
+//	dependsOn:
+//	  - db
+//	  - service: cache
+//	    maxLatency: 5      # ms
+//	    minBandwidth: 100  # Mbps
+//	    hard: false        # prefer, rather than require, these limits
+type Dependency struct {
+	Service      string   `yaml:"service" json:"service"`
+	MaxLatency   *float64 `yaml:"maxLatency,omitempty" json:"maxLatency,omitempty"`
+	MinBandwidth *float64 `yaml:"minBandwidth,omitempty" json:"minBandwidth,omitempty"`
+	// Hard defaults to true: a node that cannot meet the limits is not
+	// considered. Soft limits only lower the score of such nodes.
+	Hard *bool `yaml:"hard,omitempty" json:"hard,omitempty"`
+	// Weight scales the link's latency when the whole app is placed, so
+	// chatty links are kept shortest. It defaults to 1.
+	Weight float64 `yaml:"weight,omitempty" json:"weight,omitempty"`
+}
+
+// IsHard reports whether the dependency's network limits are requirements.
+func (d Dependency) IsHard() bool {
+	return d.Hard == nil || *d.Hard
+}
+
+// UnmarshalYAML accepts both the plain string and the mapping form.
+func (d *Dependency) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*d = Dependency{Service: value.Value}
+		return nil
+	}
+	type plain Dependency
+	var p plain
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*d = Dependency(p)
+	return nil
+}
+
+// UnmarshalJSON accepts both the plain string and the object form.
+func (d *Dependency) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*d = Dependency{Service: name}
+		return nil
+	}
+	type plain Dependency
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*d = Dependency(p)
+	return nil
+}
+
 // ServiceSpec describes a single service within a manifest.
 type ServiceSpec struct {
 	Image       string            `yaml:"image" json:"image"`
 	Ports       map[string]string `yaml:"ports" json:"ports"`
 	Env         map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
 	Volumes     []string          `yaml:"volumes,omitempty" json:"volumes,omitempty"`
-	DependsOn   []string          `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
+	DependsOn   []Dependency      `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
 	HealthCheck string            `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
 	Command     []string          `yaml:"command,omitempty" json:"command,omitempty"`
 	Resources   ServiceResources  `yaml:"resources,omitempty" json:"resources,omitempty"`
+	// StopGracePeriod is the number of seconds a container is given to shut
+	// down before being killed.
+	StopGracePeriod int `yaml:"stopGracePeriod,omitempty" json:"stopGracePeriod,omitempty"`
+	// SchedulerProfile names the scheduler profile the service's tasks are
+	// placed with; empty uses the manager's default profile.
+	SchedulerProfile string `yaml:"schedulerProfile,omitempty" json:"schedulerProfile,omitempty"`
+	// NodeSelector and NodeAffinity restrict, or express a preference for,
+	// the workers the service runs on, by worker label:
+	//
+	//	nodeSelector:
+	//	  disk: ssd
+	//	nodeAffinity:
+	//	  required:
+	//	    - matchExpressions:
+	//	        - {key: arch, operator: In, values: [amd64]}
+	//	  preferred:
+	//	    - weight: 50
+	//	      preference:
+	//	        matchExpressions:
+	//	          - {key: gpu, operator: Exists}
+	NodeSelector map[string]string  `yaml:"nodeSelector,omitempty" json:"nodeSelector,omitempty"`
+	NodeAffinity *task.NodeAffinity `yaml:"nodeAffinity,omitempty" json:"nodeAffinity,omitempty"`
+	// Labels tag the service's tasks for other services' affinity rules.
+	// Affinity and AntiAffinity place the service with, or away from,
+	// services of this app (services) or tasks of any app (matchLabels),
+	// per node, zone or region:
+	//
+	//	affinity:
+	//	  required:
+	//	    - {services: [cache], topologyKey: node}
+	//	antiAffinity:
+	//	  preferred:
+	//	    - {weight: 80, matchLabels: {tier: db}, topologyKey: zone}
+	Labels       map[string]string     `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Affinity     *task.ServiceAffinity `yaml:"affinity,omitempty" json:"affinity,omitempty"`
+	AntiAffinity *task.ServiceAffinity `yaml:"antiAffinity,omitempty" json:"antiAffinity,omitempty"`
+	// SpreadConstraints limit how unevenly the service's tasks are spread
+	// over nodes, zones or regions:
+	//
+	//	spreadConstraints:
+	//	  - {maxSkew: 1, topologyKey: zone, whenUnsatisfiable: reject}
+	SpreadConstraints []task.TopologySpreadConstraint `yaml:"spreadConstraints,omitempty" json:"spreadConstraints,omitempty"`
+	// Tolerations let the service run on workers tainted with
+	// "okube nodes taint":
+	//
+	//	tolerations:
+	//	  - {key: dedicated, value: heavy, effect: NoSchedule}
+	Tolerations []task.Toleration `yaml:"tolerations,omitempty" json:"tolerations,omitempty"`
 }
 
 // Manifest is a declarative multi-service application definition.
@@ -64,9 +172,56 @@ func ParseManifest(data []byte) (*Manifest, error) {
 		if svc.Image == "" {
 			return nil, fmt.Errorf("service %q: image is required", name)
 		}
+		if err := svc.NodeAffinity.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: nodeAffinity: %w", name, err)
+		}
+		if err := svc.Affinity.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: affinity: %w", name, err)
+		}
+		if err := svc.AntiAffinity.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: antiAffinity: %w", name, err)
+		}
+		for i, c := range svc.SpreadConstraints {
+			if err := c.Validate(); err != nil {
+				return nil, fmt.Errorf("service %q: spreadConstraints[%d]: %w", name, i, err)
+			}
+		}
+		for i, tol := range svc.Tolerations {
+			if err := tol.Validate(); err != nil {
+				return nil, fmt.Errorf("service %q: tolerations[%d]: %w", name, i, err)
+			}
+		}
+		for _, a := range []*task.ServiceAffinity{svc.Affinity, svc.AntiAffinity} {
+			if a == nil {
+				continue
+			}
+			terms := append([]task.ServiceAffinityTerm(nil), a.Required...)
+			for _, pref := range a.Preferred {
+				terms = append(terms, pref.ServiceAffinityTerm)
+			}
+			for _, term := range terms {
+				for _, other := range term.Services {
+					if _, ok := m.Services[other]; !ok {
+						return nil, fmt.Errorf("service %q: affinity rule names unknown service %q", name, other)
+					}
+				}
+			}
+		}
 		for _, dep := range svc.DependsOn {
-			if _, ok := m.Services[dep]; !ok {
-				return nil, fmt.Errorf("service %q depends on unknown service %q", name, dep)
+			if dep.Service == "" {
+				return nil, fmt.Errorf("service %q: dependsOn entry without a service name", name)
+			}
+			if _, ok := m.Services[dep.Service]; !ok {
+				return nil, fmt.Errorf("service %q depends on unknown service %q", name, dep.Service)
+			}
+			if dep.MaxLatency != nil && *dep.MaxLatency < 0 {
+				return nil, fmt.Errorf("service %q: maxLatency to %q must not be negative", name, dep.Service)
+			}
+			if dep.MinBandwidth != nil && *dep.MinBandwidth < 0 {
+				return nil, fmt.Errorf("service %q: minBandwidth to %q must not be negative", name, dep.Service)
+			}
+			if dep.Weight < 0 {
+				return nil, fmt.Errorf("service %q: weight of dependency %q must not be negative", name, dep.Service)
 			}
 		}
 	}
@@ -85,8 +240,12 @@ func ToAppGroup(m *Manifest) (*appgroup.AppGroup, error) {
 	for name, svc := range m.Services {
 		for _, dep := range svc.DependsOn {
 			edges = append(edges, appgroup.DependencyEdge{
-				From: name,
-				To:   dep,
+				From:           name,
+				To:             dep.Service,
+				MinBandwidth:   dep.MinBandwidth,
+				MaxNetworkCost: dep.MaxLatency,
+				Soft:           !dep.IsHard(),
+				Weight:         dep.Weight,
 			})
 		}
 	}
@@ -114,20 +273,30 @@ func ToTasks(m *Manifest, appID string) map[string]*task.Task {
 		}
 
 		t := &task.Task{
-			ID:           uuid.New(),
-			Name:         fmt.Sprintf("%s-%s", m.Name, name),
-			AppID:        appID,
-			ServiceID:    name,
-			State:        task.Pending,
-			Image:        svc.Image,
-			Memory:       int(svc.Resources.Memory),
-			Disk:         int(svc.Resources.Disk),
-			ExposedPorts: exposedPorts,
-			PortBindings: portBindings,
-			HealthCheck:  svc.HealthCheck,
-			Env:          envSlice,
-			Volumes:      svc.Volumes,
-			Command:      svc.Command,
+			ID:                uuid.New(),
+			Name:              fmt.Sprintf("%s-%s", m.Name, name),
+			AppID:             appID,
+			ServiceID:         name,
+			State:             task.Pending,
+			Image:             svc.Image,
+			Memory:            int(svc.Resources.Memory),
+			Disk:              int(svc.Resources.Disk),
+			Cpu:               int(math.Ceil(svc.Resources.CPU)),
+			ExposedPorts:      exposedPorts,
+			PortBindings:      portBindings,
+			HealthCheck:       svc.HealthCheck,
+			Env:               envSlice,
+			Volumes:           svc.Volumes,
+			Command:           svc.Command,
+			StopGracePeriod:   svc.StopGracePeriod,
+			SchedulerProfile:  svc.SchedulerProfile,
+			NodeSelector:      svc.NodeSelector,
+			NodeAffinity:      svc.NodeAffinity,
+			Labels:            svc.Labels,
+			Affinity:          svc.Affinity,
+			AntiAffinity:      svc.AntiAffinity,
+			SpreadConstraints: svc.SpreadConstraints,
+			Tolerations:       svc.Tolerations,
 		}
 
 		tasks[name] = t
@@ -7,7 +7,9 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/aditip149209/okube/pkg/task"
 	"github.com/aditip149209/okube/pkg/utils"
 	"github.com/aditip149209/okube/pkg/worker"
 )
@@ -29,6 +31,23 @@ type Node struct {
 	Role            string
 	TaskCount       int
 	Cpu             int
+	// CpuAllocated is the number of cores requested by tasks on the node.
+	CpuAllocated int
+	// MemoryInFlight is the part of MemoryAllocated requested by tasks
+	// placed on the node that are not running yet, so their memory is not
+	// in the node's measured usage.
+	MemoryInFlight int
+	// Labels, Arch and OS are reported by the worker at registration and let
+	// scheduler plugins match tasks to specific machines.
+	Labels map[string]string
+	Arch   string
+	OS     string
+	// Taints keep tasks that do not tolerate them off the node.
+	Taints []task.Taint
+	// StatsUpdatedAt is when Stats was last filled in. Nodes built from the
+	// manager's inventory carry a cached snapshot so scoring does not have
+	// to call the worker.
+	StatsUpdatedAt time.Time
 }
 
 type Option func(*Node)
@@ -57,6 +76,64 @@ func WithCpu(c int) Option {
 	}
 }
 
+func WithLabels(labels map[string]string) Option {
+	return func(n *Node) {
+		n.Labels = labels
+	}
+}
+
+func WithTaints(taints []task.Taint) Option {
+	return func(n *Node) {
+		n.Taints = taints
+	}
+}
+
+func WithPlatform(arch, os string) Option {
+	return func(n *Node) {
+		n.Arch = arch
+		n.OS = os
+	}
+}
+
+// WithStats sets a stats snapshot taken at the given time, along with the
+// memory and disk totals it reports.
+func WithStats(stats worker.Stats, at time.Time) Option {
+	return func(n *Node) {
+		n.Stats = stats
+		n.StatsUpdatedAt = at
+		if total := stats.MemTotalKb(); total > 0 {
+			n.Memory = int64(total)
+		}
+		if total := stats.DiskTotal(); total > 0 {
+			n.Disk = int64(total)
+		}
+	}
+}
+
+// WithAllocations sets the resources already promised to tasks on the node.
+func WithAllocations(memory, disk, tasks int) Option {
+	return func(n *Node) {
+		n.MemoryAllocated = memory
+		n.DiskAllocated = disk
+		n.TaskCount = tasks
+	}
+}
+
+// WithCpuAllocation sets the cores already requested by tasks on the node.
+func WithCpuAllocation(cpu int) Option {
+	return func(n *Node) {
+		n.CpuAllocated = cpu
+	}
+}
+
+// WithMemoryInFlight sets the memory of tasks placed on the node that have
+// not started yet.
+func WithMemoryInFlight(memory int) Option {
+	return func(n *Node) {
+		n.MemoryInFlight = memory
+	}
+}
+
 // NewNode creates and returns a new Node instance
 func NewNode(name string, ip string, role string, opts ...Option) *Node {
 
@@ -117,3 +194,21 @@ func (n *Node) GetNodeStats() (*worker.Stats, error) {
 	return &n.Stats, nil
 
 }
+
+// CurrentStats returns the cached stats snapshot when the node carries one
+// and fetches fresh stats from the worker otherwise.
+func (n *Node) CurrentStats() (*worker.Stats, error) {
+	if !n.StatsUpdatedAt.IsZero() {
+		return &n.Stats, nil
+	}
+	return n.GetNodeStats()
+}
+
+// Label returns the value of a node label and whether it is set.
+func (n *Node) Label(key string) (string, bool) {
+	if n == nil || n.Labels == nil {
+		return "", false
+	}
+	v, ok := n.Labels[key]
+	return v, ok
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aditip149209/okube/pkg/node"
+	"github.com/aditip149209/okube/pkg/task"
+)
+
+// BinpackWeights weigh memory, CPU and disk when the binpack scorer
+// averages how full a node would be.
+type BinpackWeights struct {
+	Memory float64
+	CPU    float64
+	Disk   float64
+}
+
+// DefaultBinpackWeights weigh every resource equally.
+var DefaultBinpackWeights = BinpackWeights{Memory: 1, CPU: 1, Disk: 1}
+
+// ParseBinpackWeights parses "memory=1,cpu=0.5,disk=0". Resources left out
+// keep their default weight of 1.
+func ParseBinpackWeights(s string) (BinpackWeights, error) {
+	w := DefaultBinpackWeights
+	if strings.TrimSpace(s) == "" {
+		return w, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		key, raw, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return w, fmt.Errorf("binpack weight %q: expected resource=weight", part)
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 {
+			return w, fmt.Errorf("binpack weight %q: expected a non-negative number", part)
+		}
+		switch key {
+		case "memory":
+			w.Memory = v
+		case "cpu":
+			w.CPU = v
+		case "disk":
+			w.Disk = v
+		default:
+			return w, fmt.Errorf("unknown binpack resource %q (expected memory, cpu or disk)", key)
+		}
+	}
+	if w.Memory+w.CPU+w.Disk == 0 {
+		return w, fmt.Errorf("binpack weights cannot all be zero")
+	}
+	return w, nil
+}
+
+// binpackResourceFilter rejects nodes whose requested memory, CPU or disk
+// would exceed capacity once the task is added. Resources the task does
+// not request, or whose capacity the node does not report, are not checked.
+type binpackResourceFilter struct{}
+
+func (f binpackResourceFilter) Filter(t task.Task, nodes []*node.Node) []*node.Node {
+	var candidates []*node.Node
+	for i := range nodes {
+		if f.rejectReason(t, nodes[i]) == "" {
+			candidates = append(candidates, nodes[i])
+		}
+	}
+	return candidates
+}
+
+func (binpackResourceFilter) rejectReason(t task.Task, n *node.Node) string {
+	memory := int64(t.Memory / 1000)
+	if memory > 0 && n.Memory > 0 && int64(n.MemoryAllocated)+memory > n.Memory {
+		return fmt.Sprintf("insufficient memory: requested %dKB, available %dKB", memory, n.Memory-int64(n.MemoryAllocated))
+	}
+	if t.Cpu > 0 && n.Cores > 0 && n.CpuAllocated+t.Cpu > n.Cores {
+		return fmt.Sprintf("insufficient cpu: requested %d core(s), available %d", t.Cpu, n.Cores-n.CpuAllocated)
+	}
+	if t.Disk > 0 && n.Disk > 0 {
+		return diskRejectReason(t, n)
+	}
+	return ""
+}
+
+// binpackResourceScorer prefers the nodes that would be fullest after the
+// placement, so work is consolidated onto fewer machines. A node's fullness
+// is the weighted mean of requested/capacity for memory, CPU and disk; the
+// cost is 1 minus that, so a node the task would fill exactly costs 0 and
+// an empty node close to 1. Nodes that report no capacity at all cost 1.
+type binpackResourceScorer struct {
+	weights BinpackWeights
+}
+
+func (s binpackResourceScorer) Score(t task.Task, nodes []*node.Node) map[string]float64 {
+	scores := make(map[string]float64, len(nodes))
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		var filled, weight float64
+		add := func(w float64, requested, capacity int64) {
+			if w <= 0 || capacity <= 0 {
+				return
+			}
+			share := float64(requested) / float64(capacity)
+			if share > 1 {
+				share = 1
+			}
+			filled += w * share
+			weight += w
+		}
+		add(s.weights.Memory, int64(n.MemoryAllocated)+int64(t.Memory/1000), n.Memory)
+		add(s.weights.CPU, int64(n.CpuAllocated+t.Cpu), int64(n.Cores))
+		add(s.weights.Disk, int64(n.DiskAllocated+t.Disk), n.Disk)
+
+		if weight == 0 {
+			scores[n.Name] = 1
+			continue
+		}
+		scores[n.Name] = 1 - filled/weight
+	}
+	return scores
+}
+
+func init() {
+	RegisterResourceFilter("binpack", func(PluginArgs, BuildContext) (ResourceFilterPlugin, error) {
+		return binpackResourceFilter{}, nil
+	})
+	RegisterResourceScore("binpack", func(args PluginArgs, bc BuildContext) (ResourceScorePlugin, error) {
+		w := bc.BinpackWeights
+		if w == (BinpackWeights{}) {
+			w = DefaultBinpackWeights
+		}
+		var err error
+		if w.Memory, err = args.Float("memory", w.Memory); err != nil {
+			return nil, err
+		}
+		if w.CPU, err = args.Float("cpu", w.CPU); err != nil {
+			return nil, err
+		}
+		if w.Disk, err = args.Float("disk", w.Disk); err != nil {
+			return nil, err
+		}
+		if w.Memory < 0 || w.CPU < 0 || w.Disk < 0 {
+			return nil, fmt.Errorf("binpack weights must not be negative")
+		}
+		if w.Memory+w.CPU+w.Disk == 0 {
+			return nil, fmt.Errorf("binpack weights cannot all be zero")
+		}
+		return binpackResourceScorer{weights: w}, nil
+	})
+}
@@ -0,0 +1,280 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aditip149209/okube/pkg/node"
+	"github.com/aditip149209/okube/pkg/task"
+)
+
+// Explainer is implemented by schedulers that can report how they would
+// place a task without placing it.
+type Explainer interface {
+	Explain(t task.Task, nodes []*node.Node, filterCtx *FilterContext, scoreCtx *ScoreContext) *Explanation
+}
+
+// Explanation is the outcome of a scheduling dry run.
+type Explanation struct {
+	// Pick is the node the scheduler would choose, empty when no node
+	// passed every filter.
+	Pick           string            `json:"pick,omitempty"`
+	Profile        string            `json:"profile,omitempty"`
+	Reason         string            `json:"reason,omitempty"`
+	NetworkWeight  float64           `json:"networkWeight"`
+	ResourceWeight float64           `json:"resourceWeight"`
+	Nodes          []NodeExplanation `json:"nodes"`
+}
+
+// NodeExplanation is one node's path through the pipeline. Score fields
+// are only set for nodes that passed every filter. NetworkCost and
+// NetworkScore stay unset when no dependency link had network data, in
+// which case Score is the raw resource score.
+type NodeExplanation struct {
+	Node          string          `json:"node"`
+	Filters       []FilterVerdict `json:"filters"`
+	Feasible      bool            `json:"feasible"`
+	ResourceScore *float64        `json:"resourceScore,omitempty"`
+	NetworkCost   *float64        `json:"networkCost,omitempty"`
+	NetworkScore  *float64        `json:"networkScore,omitempty"`
+	// PluginScores are the unweighted costs from the profile's extra score
+	// plugins, by plugin name.
+	PluginScores map[string]float64 `json:"pluginScores,omitempty"`
+	Score        *float64           `json:"score,omitempty"`
+}
+
+// FilterVerdict records whether one filter stage accepted a node.
+type FilterVerdict struct {
+	Filter string `json:"filter"`
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// resourceRejecter lets a resource filter explain a rejection.
+type resourceRejecter interface {
+	rejectReason(t task.Task, n *node.Node) string
+}
+
+// networkRejecter lets a network filter explain a rejection.
+type networkRejecter interface {
+	rejectReason(t task.Task, n *node.Node, filterCtx *FilterContext) string
+}
+
+// dryRunScorer is implemented by resource scorers that keep state between
+// calls, so a dry run can score without advancing it.
+type dryRunScorer interface {
+	scoreDryRun(t task.Task, nodes []*node.Node) map[string]float64
+}
+
+// Explain runs every pipeline stage for t over nodes and records each
+// filter verdict and score. Unlike Score it leaves stateful plugins, such
+// as the round-robin cursor, untouched.
+func (c *ComposableScheduler) Explain(t task.Task, nodes []*node.Node, filterCtx *FilterContext, scoreCtx *ScoreContext) *Explanation {
+	if c == nil {
+		return &Explanation{Reason: "scheduler not configured", Nodes: []NodeExplanation{}}
+	}
+	scoreCtx = c.scoreContext(scoreCtx)
+	networkWeight, resourceWeight := resolveScoreWeights(scoreCtx)
+	out := &Explanation{
+		Profile:        c.profile,
+		NetworkWeight:  networkWeight,
+		ResourceWeight: resourceWeight,
+		Nodes:          make([]NodeExplanation, 0, len(nodes)),
+	}
+
+	feasible := make([]*node.Node, 0, len(nodes))
+	index := make(map[string]int, len(nodes))
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		ne := NodeExplanation{Node: n.Name}
+
+		resourceReason := c.resourceRejectReason(t, n)
+		ne.Filters = append(ne.Filters, FilterVerdict{Filter: "resource", Passed: resourceReason == "", Reason: resourceReason})
+		if resourceReason == "" {
+			networkReason := c.networkRejectReason(t, n, filterCtx)
+			ne.Filters = append(ne.Filters, FilterVerdict{Filter: "network", Passed: networkReason == "", Reason: networkReason})
+			ne.Feasible = networkReason == ""
+		}
+		for _, f := range c.filters {
+			if !ne.Feasible {
+				break
+			}
+			reason := f.plugin.Reject(t, n, filterCtx)
+			ne.Filters = append(ne.Filters, FilterVerdict{Filter: f.name, Passed: reason == "", Reason: reason})
+			ne.Feasible = reason == ""
+		}
+
+		if ne.Feasible {
+			feasible = append(feasible, n)
+		}
+		index[n.Name] = len(out.Nodes)
+		out.Nodes = append(out.Nodes, ne)
+	}
+
+	if len(feasible) == 0 {
+		out.Reason = "no node passed every filter"
+		return out
+	}
+
+	var resourceScores map[string]float64
+	if dr, ok := c.resourceScore.(dryRunScorer); ok {
+		resourceScores = dr.scoreDryRun(t, feasible)
+	} else {
+		resourceScores = c.resourceScore.Score(t, feasible)
+	}
+
+	var final map[string]float64
+	var breakdown networkScoreBreakdown
+	if p, ok := c.networkScore.(networkScorePlugin); ok {
+		breakdown = networkAwareScores(t, feasible, resourceScores, scoreCtx, p.statistic)
+		final = breakdown.final
+	} else {
+		final = c.networkScore.Score(t, feasible, resourceScores, scoreCtx)
+	}
+	final, pluginScores := c.addPluginScores(t, feasible, final, scoreCtx)
+
+	for _, n := range feasible {
+		ne := &out.Nodes[index[n.Name]]
+		ne.ResourceScore = floatRef(resourceScores[n.Name])
+		if breakdown.networkCosts != nil {
+			ne.NetworkCost = floatRef(breakdown.networkCosts[n.Name])
+			ne.NetworkScore = floatRef(breakdown.networkNormalized[n.Name])
+		}
+		for name, costs := range pluginScores {
+			if ne.PluginScores == nil {
+				ne.PluginScores = make(map[string]float64, len(pluginScores))
+			}
+			ne.PluginScores[name] = costs[n.Name]
+		}
+		ne.Score = floatRef(final[n.Name])
+	}
+
+	if picked := c.finalSelect.Pick(final, feasible); picked != nil {
+		out.Pick = picked.Name
+	} else {
+		out.Reason = "final selection picked no node"
+	}
+	return out
+}
+
+func (c *ComposableScheduler) resourceRejectReason(t task.Task, n *node.Node) string {
+	if r, ok := c.resourceFilter.(resourceRejecter); ok {
+		return r.rejectReason(t, n)
+	}
+	if len(c.resourceFilter.Filter(t, []*node.Node{n})) == 0 {
+		return "rejected by resource filter"
+	}
+	return ""
+}
+
+func (c *ComposableScheduler) networkRejectReason(t task.Task, n *node.Node, filterCtx *FilterContext) string {
+	if r, ok := c.networkFilter.(networkRejecter); ok {
+		return r.rejectReason(t, n, filterCtx)
+	}
+	if len(c.networkFilter.Filter(t, []*node.Node{n}, filterCtx)) == 0 {
+		return "rejected by network filter"
+	}
+	return ""
+}
+
+func floatRef(v float64) *float64 {
+	return &v
+}
+
+// diskRejectReason explains why a node lacks the disk a task requests.
+func diskRejectReason(t task.Task, n *node.Node) string {
+	available := n.Disk - int64(n.DiskAllocated)
+	if checkDisk(t, available) {
+		return ""
+	}
+	return fmt.Sprintf("insufficient disk: requested %d, available %d", t.Disk, available)
+}
+
+// rejectionCauses maps a fragment of a filter's reject reason to the short
+// cause used when rejections are counted. Reasons that match none are
+// counted under their filter's name.
+var rejectionCauses = []struct {
+	fragment string
+	cause    string
+}{
+	{"is forbidden", "forbidden link"},
+	{"network partition", "network partition"},
+	{"maxNetworkCost", "exceeded max latency"},
+	{"minBandwidth", "insufficient bandwidth"},
+	{"insufficient memory", "insufficient memory"},
+	{"insufficient cpu", "insufficient cpu"},
+	{"insufficient disk", "insufficient disk"},
+	{"nodeSelector", "node selector mismatch"},
+	{"node affinity", "node affinity mismatch"},
+	{"anti-affinity:", "anti-affinity conflict"},
+	{"affinity:", "affinity unmet"},
+	{"spread:", "spread skew exceeded"},
+	{"not tolerated", "untolerated taint"},
+}
+
+func rejectionCause(filter, reason string) string {
+	for _, rc := range rejectionCauses {
+		if strings.Contains(reason, rc.fragment) {
+			return rc.cause
+		}
+	}
+	return "rejected by " + filter + " filter"
+}
+
+// Summary explains in one line why no node was picked, e.g. "no nodes
+// passed network filter: 3 exceeded max latency". Rejections by earlier
+// filters are listed after the last one. It returns "" when a node was
+// picked.
+func (e *Explanation) Summary() string {
+	if e.Pick != "" {
+		return ""
+	}
+	if len(e.Nodes) == 0 {
+		return "no schedulable nodes"
+	}
+
+	var filters []string
+	stage := make(map[string]int)
+	causes := make(map[string][]string)
+	counts := make(map[string]map[string]int)
+	for _, n := range e.Nodes {
+		for i, v := range n.Filters {
+			if v.Passed {
+				continue
+			}
+			if _, ok := counts[v.Filter]; !ok {
+				filters = append(filters, v.Filter)
+				stage[v.Filter] = i
+				counts[v.Filter] = make(map[string]int)
+			}
+			cause := rejectionCause(v.Filter, v.Reason)
+			if counts[v.Filter][cause] == 0 {
+				causes[v.Filter] = append(causes[v.Filter], cause)
+			}
+			counts[v.Filter][cause]++
+		}
+	}
+	if len(filters) == 0 {
+		return e.Reason
+	}
+	// Report filters in pipeline order, whichever node was seen first.
+	sort.SliceStable(filters, func(i, j int) bool { return stage[filters[i]] < stage[filters[j]] })
+
+	describe := func(filter string) string {
+		parts := make([]string, 0, len(causes[filter]))
+		for _, cause := range causes[filter] {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[filter][cause], cause))
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	last := filters[len(filters)-1]
+	summary := fmt.Sprintf("no nodes passed %s filter: %s", last, describe(last))
+	for i := len(filters) - 2; i >= 0; i-- {
+		summary += fmt.Sprintf("; %s filter: %s", filters[i], describe(filters[i]))
+	}
+	return summary
+}
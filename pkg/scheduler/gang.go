@@ -0,0 +1,560 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/aditip149209/okube/pkg/appgroup"
+	"github.com/aditip149209/okube/pkg/node"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/aditip149209/okube/pkg/topology"
+)
+
+// maxGangSteps bounds the placement search. When it runs out the best
+// complete placement found so far is used.
+const maxGangSteps = 200000
+
+// GangRequest is an application to place as a whole: one task per service
+// of AppGroup, the nodes it may use and the cluster around it. Filter
+// carries the topology, the tasks already placed and the schedulable node
+// names; its AppGroup and dependency fields are ignored.
+type GangRequest struct {
+	AppGroup *appgroup.AppGroup
+	Tasks    map[string]task.Task
+	Nodes    []*node.Node
+	Filter   *FilterContext
+	// SchedulerFor returns the pipeline a task's filters and scores come
+	// from.
+	SchedulerFor func(t task.Task) (Scheduler, error)
+}
+
+// GangPlacement assigns every service of an app to a node.
+type GangPlacement struct {
+	Nodes map[string]string `json:"nodes"`
+	// NetworkCost is the sum over dependency edges of the edge weight times
+	// the link latency plus any soft-limit penalty.
+	NetworkCost float64 `json:"networkCost"`
+	// Scores are each service's pipeline score on its node.
+	Scores map[string]float64 `json:"scores,omitempty"`
+	// Complete is false when the search ran out of steps before it could
+	// rule out a cheaper placement.
+	Complete bool `json:"complete"`
+}
+
+// GangError explains why no placement satisfies every hard constraint.
+type GangError struct {
+	App    string
+	Reason string
+}
+
+func (e *GangError) Error() string {
+	return fmt.Sprintf("no placement of app %s satisfies every hard constraint: %s", e.App, e.Reason)
+}
+
+// gangService is one service in search order with what is fixed for it.
+type gangService struct {
+	name       string
+	task       task.Task
+	sched      Scheduler
+	candidates []*node.Node
+	// pref is the service's pipeline score on each candidate, scaled to
+	// [0, 1] so partial sums bound the total.
+	pref  map[string]float64
+	score map[string]float64
+	// filterLatency and scoreLatency are the latency statistics its
+	// pipeline judges and scores its dependency edges with.
+	filterLatency topology.LatencyStatistic
+	scoreLatency  topology.LatencyStatistic
+}
+
+// newGangService returns the service name running t under sched.
+func newGangService(name string, t task.Task, sched Scheduler) *gangService {
+	svc := &gangService{name: name, task: t, sched: sched}
+	svc.filterLatency, svc.scoreLatency = latencyStatistics(sched)
+	return svc
+}
+
+// latencyStatistics returns the statistics sched's network filter and
+// network score plugins read, or the defaults for stages it does not have.
+func latencyStatistics(sched Scheduler) (filter, score topology.LatencyStatistic) {
+	filter, score = defaultFilterLatencyStatistic, defaultScoreLatencyStatistic
+	c, ok := sched.(*ComposableScheduler)
+	if !ok {
+		return filter, score
+	}
+	if p, ok := c.networkFilter.(networkFilterPlugin); ok && p.statistic != "" {
+		filter = p.statistic
+	}
+	if p, ok := c.networkScore.(networkScorePlugin); ok && p.statistic != "" {
+		score = p.statistic
+	}
+	return filter, score
+}
+
+type gangLink struct{ from, to string }
+
+type gangSearch struct {
+	req      GangRequest
+	nt       *topology.NetworkTopology
+	services []*gangService
+	index    map[string]int
+
+	assigned  map[string]string
+	bandwidth map[gangLink]float64
+	steps     int
+
+	best     map[string]string
+	bestCost float64
+	bestPref float64
+
+	// deepest is the furthest the search got without a complete placement
+	// and why every node was refused for the service it stopped at.
+	deepest        int
+	deepestPartial map[string]string
+	deepestReasons map[string]string
+}
+
+// PlaceGang finds a node for every service of the app such that each
+// service passes its pipeline's filters, given the services placed before
+// it, and every hard dependency edge holds in both directions, counting
+// the bandwidth the app's own links take. Among such placements it returns
+// the one with the lowest network cost, breaking ties by pipeline score.
+func PlaceGang(req GangRequest) (*GangPlacement, error) {
+	appID := ""
+	if req.AppGroup != nil {
+		appID = req.AppGroup.AppID
+	}
+	s := newGangSearch(req)
+	base := s.baseFilter()
+
+	// Candidates on their own, before any other service of the app is
+	// placed. A service with none fails the whole app straight away.
+	static := &FilterContext{NetworkTopology: base.NetworkTopology, Placements: base.Placements, Nodes: base.Nodes}
+	var unplaceable []string
+	names := make([]string, 0, len(req.Tasks))
+	for name := range req.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := req.Tasks[name]
+		sched, err := req.SchedulerFor(t)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		svc := newGangService(name, t, sched)
+		svc.candidates = sched.SelectCandidateNodes(t, req.Nodes, static)
+		if len(svc.candidates) == 0 {
+			reason := "no node passed its filters"
+			if explainer, ok := sched.(Explainer); ok {
+				if summary := explainer.Explain(t, req.Nodes, static, &ScoreContext{Filter: static}).Summary(); summary != "" {
+					reason = summary
+				}
+			}
+			unplaceable = append(unplaceable, fmt.Sprintf("service %s: %s", name, reason))
+			continue
+		}
+		svc.score, svc.pref = gangPreferences(sched, t, req.Nodes, svc.candidates, static)
+		s.services = append(s.services, svc)
+	}
+	if len(unplaceable) > 0 {
+		return nil, &GangError{App: appID, Reason: strings.Join(unplaceable, "; ")}
+	}
+
+	s.order()
+	s.search(0, 0, 0)
+
+	if s.best == nil {
+		if s.steps >= maxGangSteps {
+			return nil, &GangError{App: appID, Reason: fmt.Sprintf("no placement found within %d search steps", maxGangSteps)}
+		}
+		return nil, &GangError{App: appID, Reason: s.explainFailure()}
+	}
+
+	out := &GangPlacement{
+		Nodes:       s.best,
+		NetworkCost: s.bestCost,
+		Scores:      make(map[string]float64, len(s.best)),
+		Complete:    s.steps < maxGangSteps,
+	}
+	for _, svc := range s.services {
+		if v, ok := svc.score[s.best[svc.name]]; ok {
+			out.Scores[svc.name] = v
+		}
+	}
+	return out, nil
+}
+
+// CheckGang re-checks a placement PlaceGang found against req, typically
+// built from fresher cluster state, and returns why it no longer holds, or
+// "" if every service still passes its filters and every hard edge holds.
+func CheckGang(req GangRequest, placement *GangPlacement) (string, error) {
+	s := newGangSearch(req)
+	names := make([]string, 0, len(req.Tasks))
+	for name := range req.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		t := req.Tasks[name]
+		sched, err := req.SchedulerFor(t)
+		if err != nil {
+			return "", fmt.Errorf("service %s: %w", name, err)
+		}
+		s.services = append(s.services, newGangService(name, t, sched))
+		s.index[name] = i
+	}
+
+	nodes := make(map[string]*node.Node, len(req.Nodes))
+	for _, n := range req.Nodes {
+		if n != nil {
+			nodes[n.Name] = n
+		}
+	}
+	for _, svc := range s.services {
+		nodeName := placement.Nodes[svc.name]
+		n, ok := nodes[nodeName]
+		if !ok {
+			return fmt.Sprintf("service %s: node %s is no longer schedulable", svc.name, nodeName), nil
+		}
+		opt, reason := s.evaluate(svc, n)
+		if reason != "" {
+			return fmt.Sprintf("service %s on %s: %s", svc.name, nodeName, reason), nil
+		}
+		s.assigned[svc.name] = nodeName
+		for link, mbps := range opt.used {
+			s.bandwidth[link] += mbps
+		}
+	}
+	return "", nil
+}
+
+func newGangSearch(req GangRequest) *gangSearch {
+	var nt *topology.NetworkTopology
+	if req.Filter != nil {
+		nt = req.Filter.NetworkTopology
+	}
+	return &gangSearch{
+		req:       req,
+		nt:        nt,
+		index:     make(map[string]int),
+		assigned:  make(map[string]string),
+		bandwidth: make(map[gangLink]float64),
+		bestCost:  math.Inf(1),
+		bestPref:  math.Inf(1),
+		deepest:   -1,
+	}
+}
+
+// gangPreferences returns the pipeline scores of t on its candidates and
+// the same scores scaled to [0, 1]. The dry run leaves stateful scorers
+// such as round robin untouched.
+func gangPreferences(sched Scheduler, t task.Task, nodes, candidates []*node.Node, filterCtx *FilterContext) (map[string]float64, map[string]float64) {
+	scores := make(map[string]float64, len(candidates))
+	if explainer, ok := sched.(Explainer); ok {
+		for _, ne := range explainer.Explain(t, nodes, filterCtx, &ScoreContext{Filter: filterCtx}).Nodes {
+			if ne.Score != nil {
+				scores[ne.Node] = *ne.Score
+			}
+		}
+	}
+
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for _, n := range candidates {
+		v := scores[n.Name]
+		lowest = math.Min(lowest, v)
+		highest = math.Max(highest, v)
+	}
+	pref := make(map[string]float64, len(candidates))
+	for _, n := range candidates {
+		if highest > lowest {
+			pref[n.Name] = (scores[n.Name] - lowest) / (highest - lowest)
+		} else {
+			pref[n.Name] = 0
+		}
+	}
+	return scores, pref
+}
+
+// order puts the service with the fewest candidates first, then keeps
+// taking the service with the most edges to those already ordered, so
+// edge constraints prune the search as early as possible.
+func (s *gangSearch) order() {
+	edgesBetween := func(a, b string) int {
+		n := 0
+		if s.req.AppGroup == nil {
+			return 0
+		}
+		for _, e := range s.req.AppGroup.Edges {
+			if (e.From == a && e.To == b) || (e.From == b && e.To == a) {
+				n++
+			}
+		}
+		return n
+	}
+
+	remaining := s.services
+	ordered := make([]*gangService, 0, len(remaining))
+	for len(remaining) > 0 {
+		best := 0
+		bestLinks := -1
+		for i, svc := range remaining {
+			links := 0
+			for _, o := range ordered {
+				links += edgesBetween(svc.name, o.name)
+			}
+			if bestLinks >= 0 {
+				current := remaining[best]
+				if links < bestLinks || (links == bestLinks && len(svc.candidates) > len(current.candidates)) {
+					continue
+				}
+				if links == bestLinks && len(svc.candidates) == len(current.candidates) && svc.name > current.name {
+					continue
+				}
+			}
+			best, bestLinks = i, links
+		}
+		ordered = append(ordered, remaining[best])
+		remaining = append(remaining[:best:best], remaining[best+1:]...)
+	}
+	s.services = ordered
+	for i, svc := range ordered {
+		s.index[svc.name] = i
+	}
+}
+
+type gangOption struct {
+	node *node.Node
+	cost float64
+	pref float64
+	used map[gangLink]float64
+}
+
+func (s *gangSearch) search(depth int, cost, pref float64) {
+	if depth == len(s.services) {
+		if cost < s.bestCost || (cost == s.bestCost && pref < s.bestPref) {
+			s.best = make(map[string]string, len(s.assigned))
+			for k, v := range s.assigned {
+				s.best[k] = v
+			}
+			s.bestCost, s.bestPref = cost, pref
+		}
+		return
+	}
+	if s.steps >= maxGangSteps {
+		return
+	}
+	s.steps++
+
+	svc := s.services[depth]
+	reasons := make(map[string]string)
+	var options []gangOption
+	for _, n := range svc.candidates {
+		opt, reason := s.evaluate(svc, n)
+		if reason != "" {
+			reasons[n.Name] = reason
+			continue
+		}
+		options = append(options, opt)
+	}
+	if len(options) == 0 {
+		if depth > s.deepest {
+			s.deepest = depth
+			s.deepestPartial = make(map[string]string, len(s.assigned))
+			for k, v := range s.assigned {
+				s.deepestPartial[k] = v
+			}
+			s.deepestReasons = reasons
+		}
+		return
+	}
+
+	sort.SliceStable(options, func(i, j int) bool {
+		if options[i].cost != options[j].cost {
+			return options[i].cost < options[j].cost
+		}
+		if options[i].pref != options[j].pref {
+			return options[i].pref < options[j].pref
+		}
+		return options[i].node.Name < options[j].node.Name
+	})
+
+	for _, opt := range options {
+		nextCost, nextPref := cost+opt.cost, pref+opt.pref
+		// Costs and preferences never go down as services are added, so
+		// a partial placement no better than the best complete one is
+		// dropped.
+		if nextCost > s.bestCost || (nextCost == s.bestCost && nextPref >= s.bestPref) {
+			continue
+		}
+		s.assigned[svc.name] = opt.node.Name
+		for link, mbps := range opt.used {
+			s.bandwidth[link] += mbps
+		}
+		s.search(depth+1, nextCost, nextPref)
+		for link, mbps := range opt.used {
+			s.bandwidth[link] -= mbps
+		}
+		delete(s.assigned, svc.name)
+	}
+}
+
+// evaluate checks placing svc on n next to the services assigned so far
+// and returns the network cost it adds, or why it is refused.
+func (s *gangSearch) evaluate(svc *gangService, n *node.Node) (gangOption, string) {
+	opt := gangOption{node: n, pref: svc.pref[n.Name], used: make(map[gangLink]float64)}
+
+	// Every dependency edge between svc and an assigned service.
+	if s.req.AppGroup != nil {
+		var edges []appgroup.DependencyEdge
+		edges = append(edges, s.req.AppGroup.GetDependencies(svc.name)...)
+		edges = append(edges, s.req.AppGroup.GetDependents(svc.name)...)
+		for _, e := range edges {
+			fromNode, toNode := n.Name, s.assigned[e.To]
+			if e.To == svc.name {
+				fromNode, toNode = s.assigned[e.From], n.Name
+			}
+			if fromNode == "" || toNode == "" {
+				continue
+			}
+			// An edge is judged the way its source service's pipeline
+			// judges its dependencies.
+			from := svc
+			if e.To == svc.name {
+				from = s.services[s.index[e.From]]
+			}
+			if reason := s.edgeViolation(e, fromNode, toNode, from.filterLatency, opt.used); reason != "" {
+				return opt, fmt.Sprintf("edge %s→%s: %s", e.From, e.To, reason)
+			}
+			opt.cost += s.edgeCost(e, fromNode, toNode, from.scoreLatency)
+		}
+	}
+
+	// The pipeline's filters, with the app's assigned services counted as
+	// placed and their requests added to the nodes they take.
+	placed := n
+	filterCtx := &FilterContext{
+		NetworkTopology: s.nt,
+		Placements:      append([]TaskPlacement(nil), s.baseFilter().Placements...),
+		Nodes:           s.baseFilter().Nodes,
+	}
+	for name, nodeName := range s.assigned {
+		other := s.services[s.index[name]].task
+		filterCtx.Placements = append(filterCtx.Placements, TaskPlacement{Task: &other, Node: nodeName})
+		if nodeName == n.Name {
+			if placed == n {
+				copied := *n
+				placed = &copied
+			}
+			placed.MemoryAllocated += other.Memory / 1000
+			placed.MemoryInFlight += other.Memory / 1000
+			placed.DiskAllocated += other.Disk
+			placed.CpuAllocated += other.Cpu
+			placed.TaskCount++
+		}
+	}
+	if len(svc.sched.SelectCandidateNodes(svc.task, []*node.Node{placed}, filterCtx)) == 0 {
+		return opt, filterRejection(svc.sched, svc.task, placed, filterCtx)
+	}
+	return opt, ""
+}
+
+func (s *gangSearch) baseFilter() *FilterContext {
+	if s.req.Filter == nil {
+		return &FilterContext{}
+	}
+	return s.req.Filter
+}
+
+// edgeViolation applies the network filter's hard checks, judged by stat,
+// to the link of e and also refuses it when the app's links already placed
+// on it leave too little bandwidth. Bandwidth the edge would take is added
+// to used.
+func (s *gangSearch) edgeViolation(e appgroup.DependencyEdge, fromNode, toNode string, stat topology.LatencyStatistic, used map[gangLink]float64) string {
+	if s.nt == nil || fromNode == toNode {
+		return ""
+	}
+	filterCtx := &FilterContext{NetworkTopology: s.nt, DependencyNodeByService: map[string]string{e.To: toNode}}
+	if reason := hardNetworkConstraintViolation(fromNode, []appgroup.DependencyEdge{e}, filterCtx, stat); reason != "" {
+		return reason
+	}
+	if e.Soft || e.MinBandwidth == nil || *e.MinBandwidth <= 0 {
+		return ""
+	}
+
+	link := gangLink{from: fromNode, to: toNode}
+	bandwidth, ok := s.nt.GetAvailableBandwidth(fromNode, toNode)
+	if !ok {
+		bandwidth, ok = s.nt.GetBandwidth(fromNode, toNode)
+	}
+	if ok {
+		left := bandwidth - s.bandwidth[link] - used[link]
+		if left < *e.MinBandwidth {
+			return fmt.Sprintf("bandwidth to %s (%s) left after the app's other links is %.1f Mbps, below minBandwidth %.1f", e.To, toNode, left, *e.MinBandwidth)
+		}
+	}
+	used[link] += *e.MinBandwidth
+	return ""
+}
+
+// edgeCost is the weighted latency of e's link, read with stat, plus any
+// soft-limit penalty. Services on the same node cost nothing.
+func (s *gangSearch) edgeCost(e appgroup.DependencyEdge, fromNode, toNode string, stat topology.LatencyStatistic) float64 {
+	if s.nt == nil || fromNode == toNode {
+		return 0
+	}
+	latency, _, ok := s.nt.GetLatencyOrEstimate(fromNode, toNode, stat)
+	cost := 0.0
+	if ok {
+		cost = latency
+	}
+	if e.Soft {
+		cost += softConstraintPenalty(s.nt, fromNode, toNode, e, latency, ok)
+	}
+	return e.EffectiveWeight() * cost
+}
+
+// filterRejection returns the reason the first filter refusing n gives.
+func filterRejection(sched Scheduler, t task.Task, n *node.Node, filterCtx *FilterContext) string {
+	if explainer, ok := sched.(Explainer); ok {
+		for _, ne := range explainer.Explain(t, []*node.Node{n}, filterCtx, &ScoreContext{Filter: filterCtx}).Nodes {
+			for _, v := range ne.Filters {
+				if !v.Passed {
+					return fmt.Sprintf("%s filter: %s", v.Filter, v.Reason)
+				}
+			}
+		}
+	}
+	return "rejected by the scheduler's filters"
+}
+
+// explainFailure describes the furthest partial placement the search
+// reached and why each node was refused for the next service.
+func (s *gangSearch) explainFailure() string {
+	if s.deepest < 0 {
+		return "no placement found"
+	}
+	svc := s.services[s.deepest]
+
+	var partial []string
+	for _, placed := range s.services[:s.deepest] {
+		partial = append(partial, fmt.Sprintf("%s→%s", placed.name, s.deepestPartial[placed.name]))
+	}
+	nodes := make([]string, 0, len(s.deepestReasons))
+	for name := range s.deepestReasons {
+		nodes = append(nodes, name)
+	}
+	sort.Strings(nodes)
+	reasons := make([]string, 0, len(nodes))
+	for _, name := range nodes {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", name, s.deepestReasons[name]))
+	}
+
+	if len(partial) == 0 {
+		return fmt.Sprintf("no node for service %s (%s)", svc.name, strings.Join(reasons, "; "))
+	}
+	return fmt.Sprintf("after placing %s, no node is left for service %s (%s)",
+		strings.Join(partial, ", "), svc.name, strings.Join(reasons, "; "))
+}
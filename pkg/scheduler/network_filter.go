@@ -1,12 +1,19 @@
 package scheduler
 
 import (
+	"fmt"
+
 	"github.com/aditip149209/okube/pkg/appgroup"
 	"github.com/aditip149209/okube/pkg/node"
 	"github.com/aditip149209/okube/pkg/task"
+	"github.com/aditip149209/okube/pkg/topology"
 )
 
-func filterNodesByNetworkConstraints(t task.Task, candidates []*node.Node, filterCtx *FilterContext) []*node.Node {
+// defaultFilterLatencyStatistic is robust to the occasional slow probe, so a
+// single outlier cannot push a link past MaxNetworkCost.
+const defaultFilterLatencyStatistic = topology.LatencyP50
+
+func filterNodesByNetworkConstraints(t task.Task, candidates []*node.Node, filterCtx *FilterContext, stat topology.LatencyStatistic) []*node.Node {
 	if len(candidates) == 0 || filterCtx == nil || filterCtx.AppGroup == nil || filterCtx.NetworkTopology == nil {
 		return candidates
 	}
@@ -29,7 +36,7 @@ func filterNodesByNetworkConstraints(t task.Task, candidates []*node.Node, filte
 		if candidate == nil {
 			continue
 		}
-		if violatesHardNetworkConstraint(candidate.Name, edges, filterCtx) {
+		if hardNetworkConstraintViolation(candidate.Name, edges, filterCtx, stat) != "" {
 			continue
 		}
 		accepted = append(accepted, candidate)
@@ -38,17 +45,48 @@ func filterNodesByNetworkConstraints(t task.Task, candidates []*node.Node, filte
 	return accepted
 }
 
-func violatesHardNetworkConstraint(candidateNodeID string, edges []appgroup.DependencyEdge, filterCtx *FilterContext) bool {
+// networkRejectReason explains why the network filter rejects candidate for
+// t, or returns "" when it accepts it.
+func networkRejectReason(t task.Task, candidate *node.Node, filterCtx *FilterContext, stat topology.LatencyStatistic) string {
+	if candidate == nil || filterCtx == nil || filterCtx.AppGroup == nil || filterCtx.NetworkTopology == nil {
+		return ""
+	}
+	serviceID := serviceIDForTask(&t)
+	if serviceID == "" {
+		return ""
+	}
+	return hardNetworkConstraintViolation(candidate.Name, filterCtx.AppGroup.GetDependencies(serviceID), filterCtx, stat)
+}
+
+// hardNetworkConstraintViolation returns a description of the first
+// dependency edge whose hard limits placing the service on candidateNodeID
+// would break, or "" if there is none.
+func hardNetworkConstraintViolation(candidateNodeID string, edges []appgroup.DependencyEdge, filterCtx *FilterContext, stat topology.LatencyStatistic) string {
 	for _, edge := range edges {
 		depNodeID, ok := filterCtx.DependencyNodeByService[edge.To]
 		if !ok || depNodeID == "" {
 			continue
 		}
 
+		if candidateNodeID != depNodeID && filterCtx.NetworkTopology.IsLinkForbidden(candidateNodeID, depNodeID) {
+			return fmt.Sprintf("link to %s (%s) is forbidden", edge.To, depNodeID)
+		}
+
+		// A node cut off from the dependency's partition cannot reach it at
+		// all, whatever its last measured latency was.
+		if !filterCtx.NetworkTopology.SamePartition(candidateNodeID, depNodeID) {
+			return fmt.Sprintf("in a different network partition from %s (%s)", edge.To, depNodeID)
+		}
+
+		// Soft limits are left to the network score.
+		if edge.Soft {
+			continue
+		}
+
 		if edge.MaxNetworkCost != nil {
-			if latency, ok := filterCtx.NetworkTopology.GetLatency(candidateNodeID, depNodeID); ok {
+			if latency, _, ok := filterCtx.NetworkTopology.GetLatencyOrEstimate(candidateNodeID, depNodeID, stat); ok {
 				if latency > *edge.MaxNetworkCost {
-					return true
+					return fmt.Sprintf("latency to %s (%s) is %.1f, above maxNetworkCost %.1f", edge.To, depNodeID, latency, *edge.MaxNetworkCost)
 				}
 			}
 		}
@@ -60,11 +98,11 @@ func violatesHardNetworkConstraint(candidateNodeID string, edges []appgroup.Depe
 			}
 			if ok {
 				if bandwidth < *edge.MinBandwidth {
-					return true
+					return fmt.Sprintf("bandwidth to %s (%s) is %.1f Mbps, below minBandwidth %.1f", edge.To, depNodeID, bandwidth, *edge.MinBandwidth)
 				}
 			}
 		}
 	}
 
-	return false
+	return ""
 }
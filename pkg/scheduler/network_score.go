@@ -3,32 +3,57 @@ package scheduler
 import (
 	"math"
 
+	"github.com/aditip149209/okube/pkg/appgroup"
 	"github.com/aditip149209/okube/pkg/node"
 	"github.com/aditip149209/okube/pkg/task"
+	"github.com/aditip149209/okube/pkg/topology"
 )
 
 const (
 	defaultNetworkWeight  = 0.7
 	defaultResourceWeight = 0.3
+
+	// defaultScoreLatencyStatistic tracks recent conditions while damping
+	// individual spikes.
+	defaultScoreLatencyStatistic = topology.LatencyEWMA
+
+	// softViolationPenalty is the network cost, in latency units, added for
+	// each soft dependency limit a node would break.
+	softViolationPenalty = 100.0
 )
 
-func applyNetworkAwareScore(t task.Task, nodes []*node.Node, resourceScores map[string]float64, scoreCtx *ScoreContext) map[string]float64 {
+func applyNetworkAwareScore(t task.Task, nodes []*node.Node, resourceScores map[string]float64, scoreCtx *ScoreContext, stat topology.LatencyStatistic) map[string]float64 {
+	return networkAwareScores(t, nodes, resourceScores, scoreCtx, stat).final
+}
+
+// networkScoreBreakdown keeps the intermediate values of the network-aware
+// score so they can be explained. networkCosts is nil when no dependency
+// link had data, in which case final is the resource scores unchanged.
+type networkScoreBreakdown struct {
+	networkCosts       map[string]float64
+	networkNormalized  map[string]float64
+	resourceNormalized map[string]float64
+	final              map[string]float64
+}
+
+func networkAwareScores(t task.Task, nodes []*node.Node, resourceScores map[string]float64, scoreCtx *ScoreContext, stat topology.LatencyStatistic) networkScoreBreakdown {
+	unchanged := networkScoreBreakdown{final: resourceScores}
 	if len(nodes) == 0 {
-		return resourceScores
+		return unchanged
 	}
 
 	if scoreCtx == nil || scoreCtx.Filter == nil || scoreCtx.Filter.AppGroup == nil || scoreCtx.Filter.NetworkTopology == nil {
-		return resourceScores
+		return unchanged
 	}
 
 	serviceID := serviceIDForTask(&t)
 	if serviceID == "" {
-		return resourceScores
+		return unchanged
 	}
 
 	edges := scoreCtx.Filter.AppGroup.GetDependencies(serviceID)
 	if len(edges) == 0 {
-		return resourceScores
+		return unchanged
 	}
 
 	networkCosts := make(map[string]float64, len(nodes))
@@ -44,12 +69,17 @@ func applyNetworkAwareScore(t task.Task, nodes []*node.Node, resourceScores map[
 			if !ok || depNodeID == "" {
 				continue
 			}
-			latency, ok := scoreCtx.Filter.NetworkTopology.GetLatency(n.Name, depNodeID)
-			if !ok {
-				continue
+			latency, _, hasLatency := scoreCtx.Filter.NetworkTopology.GetLatencyOrEstimate(n.Name, depNodeID, stat)
+			if hasLatency {
+				cost += latency
+				usedMetric = true
+			}
+			if edge.Soft {
+				if penalty := softConstraintPenalty(scoreCtx.Filter.NetworkTopology, n.Name, depNodeID, edge, latency, hasLatency); penalty > 0 {
+					cost += penalty
+					usedMetric = true
+				}
 			}
-			cost += latency
-			usedMetric = true
 		}
 		if usedMetric {
 			hasNetworkData = true
@@ -58,7 +88,7 @@ func applyNetworkAwareScore(t task.Task, nodes []*node.Node, resourceScores map[
 	}
 
 	if !hasNetworkData {
-		return resourceScores
+		return unchanged
 	}
 
 	networkScores := normalizeScores(nodes, networkCosts)
@@ -73,7 +103,36 @@ func applyNetworkAwareScore(t task.Task, nodes []*node.Node, resourceScores map[
 		final[n.Name] = networkWeight*networkScores[n.Name] + resourceWeight*resourceNormalized[n.Name]
 	}
 
-	return final
+	return networkScoreBreakdown{
+		networkCosts:       networkCosts,
+		networkNormalized:  networkScores,
+		resourceNormalized: resourceNormalized,
+		final:              final,
+	}
+}
+
+// softConstraintPenalty returns the extra network cost of placing a service
+// on nodeID when the link to depNodeID breaks a soft limit of edge. A broken
+// latency limit also costs the excess latency, so nearer misses rank better.
+func softConstraintPenalty(nt *topology.NetworkTopology, nodeID, depNodeID string, edge appgroup.DependencyEdge, latency float64, hasLatency bool) float64 {
+	if nodeID == depNodeID {
+		return 0
+	}
+
+	penalty := 0.0
+	if edge.MaxNetworkCost != nil && hasLatency && latency > *edge.MaxNetworkCost {
+		penalty += softViolationPenalty + latency - *edge.MaxNetworkCost
+	}
+	if edge.MinBandwidth != nil {
+		bandwidth, ok := nt.GetAvailableBandwidth(nodeID, depNodeID)
+		if !ok {
+			bandwidth, ok = nt.GetBandwidth(nodeID, depNodeID)
+		}
+		if ok && bandwidth < *edge.MinBandwidth {
+			penalty += softViolationPenalty
+		}
+	}
+	return penalty
 }
 
 func normalizeScores(nodes []*node.Node, raw map[string]float64) map[string]float64 {
@@ -126,7 +185,9 @@ func normalizeScores(nodes []*node.Node, raw map[string]float64) map[string]floa
 func resolveScoreWeights(scoreCtx *ScoreContext) (float64, float64) {
 	nw := defaultNetworkWeight
 	rw := defaultResourceWeight
-	if scoreCtx != nil {
+	if scoreCtx != nil && scoreCtx.ExplicitWeights {
+		nw, rw = scoreCtx.NetworkWeight, scoreCtx.ResourceWeight
+	} else if scoreCtx != nil {
 		if scoreCtx.NetworkWeight > 0 {
 			nw = scoreCtx.NetworkWeight
 		}
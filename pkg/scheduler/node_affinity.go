@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aditip149209/okube/pkg/node"
+	"github.com/aditip149209/okube/pkg/task"
+)
+
+// nodeAffinityFilter rejects nodes whose labels do not satisfy the task's
+// nodeSelector or its required node affinity.
+type nodeAffinityFilter struct{}
+
+func (nodeAffinityFilter) Reject(t task.Task, n *node.Node, filterCtx *FilterContext) string {
+	keys := make([]string, 0, len(t.NodeSelector))
+	for key := range t.NodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		want := t.NodeSelector[key]
+		have, ok := n.Label(key)
+		if !ok {
+			return fmt.Sprintf("nodeSelector %s=%s: label not set", key, want)
+		}
+		if have != want {
+			return fmt.Sprintf("nodeSelector %s=%s: node has %s=%s", key, want, key, have)
+		}
+	}
+
+	if !t.NodeAffinity.RequiredMatches(n.Labels) {
+		return "no required node affinity term matches the node's labels"
+	}
+	return ""
+}
+
+// nodeAffinityScorer prefers nodes that match more of the task's preferred
+// node affinity terms. The cost is the share of preference weight a node
+// misses, so a node matching every term costs 0.
+type nodeAffinityScorer struct{}
+
+func (nodeAffinityScorer) Score(t task.Task, nodes []*node.Node, scoreCtx *ScoreContext) map[string]float64 {
+	costs := make(map[string]float64, len(nodes))
+	var total int
+	if t.NodeAffinity != nil {
+		for _, pref := range t.NodeAffinity.Preferred {
+			total += pref.Weight
+		}
+	}
+
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		if total == 0 {
+			costs[n.Name] = 0
+			continue
+		}
+		matched := 0
+		for _, pref := range t.NodeAffinity.Preferred {
+			if pref.Preference.Matches(n.Labels) {
+				matched += pref.Weight
+			}
+		}
+		costs[n.Name] = 1 - float64(matched)/float64(total)
+	}
+	return costs
+}
+
+func init() {
+	RegisterFilter("nodeAffinity", func(PluginArgs, BuildContext) (FilterPlugin, error) {
+		return nodeAffinityFilter{}, nil
+	})
+	RegisterScore("nodeAffinity", func(PluginArgs, BuildContext) (ScorePlugin, error) {
+		return nodeAffinityScorer{}, nil
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/aditip149209/okube/pkg/appgroup"
 	"github.com/aditip149209/okube/pkg/node"
 	"github.com/aditip149209/okube/pkg/task"
+	"github.com/aditip149209/okube/pkg/topology"
 )
 
 // QueueSortCapable marks schedulers that can order pending tasks before
@@ -39,32 +40,98 @@ type FinalSelectionPlugin interface {
 // QueueSort -> ResourceFilter -> NetworkFilter -> ResourceScore ->
 // NetworkScore -> FinalSelection.
 type ComposableScheduler struct {
+	profile        string
 	queueSorter    *QueueSorter
 	resourceFilter ResourceFilterPlugin
 	networkFilter  NetworkFilterPlugin
+	filters        []namedFilter
 	resourceScore  ResourceScorePlugin
 	networkScore   NetworkScorePlugin
+	scores         []weightedScore
 	finalSelect    FinalSelectionPlugin
+	// networkWeight and resourceWeight are the profile's weights, used
+	// when the score context does not set its own.
+	networkWeight  float64
+	resourceWeight float64
 }
 
-func NewPipelineScheduler(schedulerType, queueSortStrategy string) Scheduler {
-	resourceFilter := ResourceFilterPlugin(roundRobinResourceFilter{})
-	resourceScore := ResourceScorePlugin(newRoundRobinResourceScorer())
+// namedFilter is an extra filter stage with the name it was registered as.
+type namedFilter struct {
+	name   string
+	plugin FilterPlugin
+}
+
+// weightedScore is an extra score stage and the weight of its costs.
+type weightedScore struct {
+	name   string
+	weight float64
+	plugin ScorePlugin
+}
+
+// PipelineOption customises the plugins built by NewPipelineScheduler.
+type PipelineOption func(*pipelineOptions)
 
-	switch schedulerType {
-	case "epvm":
-		resourceFilter = epvmResourceFilter{}
-		resourceScore = epvmResourceScorer{}
+type pipelineOptions struct {
+	filterLatency  topology.LatencyStatistic
+	scoreLatency   topology.LatencyStatistic
+	binpackWeights BinpackWeights
+}
+
+// WithLatencyStatistics selects which latency statistic the network filter
+// and network score plugins read from the topology. Empty values keep the
+// defaults (p50 for filtering, EWMA for scoring).
+func WithLatencyStatistics(filter, score topology.LatencyStatistic) PipelineOption {
+	return func(o *pipelineOptions) {
+		if filter != "" {
+			o.filterLatency = filter
+		}
+		if score != "" {
+			o.scoreLatency = score
+		}
 	}
+}
+
+// WithBinpackWeights sets the per-resource weights of the binpack scorer
+// when a profile's arguments leave them out.
+func WithBinpackWeights(w BinpackWeights) PipelineOption {
+	return func(o *pipelineOptions) {
+		o.binpackWeights = w
+	}
+}
 
-	return &ComposableScheduler{
-		queueSorter:    NewQueueSorter(queueSortStrategy),
-		resourceFilter: resourceFilter,
-		networkFilter:  networkFilterPlugin{},
-		resourceScore:  resourceScore,
-		networkScore:   networkScorePlugin{},
-		finalSelect:    lowestScoreSelector{},
+// NewPipelineScheduler builds the default profile for schedulerType
+// (roundrobin, epvm or binpack).
+func NewPipelineScheduler(schedulerType, queueSortStrategy string, opts ...PipelineOption) Scheduler {
+	c, err := NewProfileScheduler(DefaultProfile(schedulerType), schedulerType, queueSortStrategy, opts...)
+	if err != nil {
+		// The default profile only names built-in plugins.
+		panic(err)
 	}
+	return c
+}
+
+// Profile returns the name of the profile the pipeline was built from.
+func (c *ComposableScheduler) Profile() string {
+	if c == nil {
+		return ""
+	}
+	return c.profile
+}
+
+// scoreContext returns scoreCtx with the profile's weights filled in when
+// it does not set any.
+func (c *ComposableScheduler) scoreContext(scoreCtx *ScoreContext) *ScoreContext {
+	if scoreCtx != nil && (scoreCtx.ExplicitWeights || scoreCtx.NetworkWeight > 0 || scoreCtx.ResourceWeight > 0) {
+		return scoreCtx
+	}
+	out := &ScoreContext{}
+	if scoreCtx != nil {
+		*out = *scoreCtx
+	}
+	out.NetworkWeight = c.networkWeight
+	out.ResourceWeight = c.resourceWeight
+	out.ExplicitWeights = true
+	return out
 }
 
 func (c *ComposableScheduler) QueueSort(tasks []*task.Task, groups []*appgroup.AppGroup) []*task.Task {
@@ -82,7 +149,18 @@ func (c *ComposableScheduler) SelectCandidateNodes(t task.Task, nodes []*node.No
 	// 2) Resource feasibility filter.
 	resourceCandidates := c.resourceFilter.Filter(t, nodes)
 	// 3) Network filter.
-	return c.networkFilter.Filter(t, resourceCandidates, filterCtx)
+	candidates := c.networkFilter.Filter(t, resourceCandidates, filterCtx)
+	// Profile filters, in order.
+	for _, f := range c.filters {
+		accepted := candidates[:0:0]
+		for _, n := range candidates {
+			if f.plugin.Reject(t, n, filterCtx) == "" {
+				accepted = append(accepted, n)
+			}
+		}
+		candidates = accepted
+	}
+	return candidates
 }
 
 func (c *ComposableScheduler) Score(t task.Task, nodes []*node.Node, scoreCtx *ScoreContext) map[string]float64 {
@@ -90,10 +168,42 @@ func (c *ComposableScheduler) Score(t task.Task, nodes []*node.Node, scoreCtx *S
 		return map[string]float64{}
 	}
 
+	scoreCtx = c.scoreContext(scoreCtx)
 	// 4) Resource scoring.
 	resourceScores := c.resourceScore.Score(t, nodes)
 	// 5) Network scoring.
-	return c.networkScore.Score(t, nodes, resourceScores, scoreCtx)
+	final := c.networkScore.Score(t, nodes, resourceScores, scoreCtx)
+	// Profile scores, weighted and added.
+	final, _ = c.addPluginScores(t, nodes, final, scoreCtx)
+	return final
+}
+
+// addPluginScores returns base plus each profile score plugin's weighted
+// costs, along with the unweighted costs per plugin. base is not modified.
+// Depending on the scheduler type and on whether network data exists, base
+// is a 0-100 blend or a raw resource score, so it is first rescaled to
+// [0, 1] across nodes; a plugin of weight 1 then counts as much as the
+// whole base spread, whatever its origin.
+func (c *ComposableScheduler) addPluginScores(t task.Task, nodes []*node.Node, base map[string]float64, scoreCtx *ScoreContext) (map[string]float64, map[string]map[string]float64) {
+	if len(c.scores) == 0 {
+		return base, nil
+	}
+	final := normalizeScores(nodes, base)
+	for name, score := range final {
+		final[name] = score / 100
+	}
+	byPlugin := make(map[string]map[string]float64, len(c.scores))
+	for _, s := range c.scores {
+		costs := s.plugin.Score(t, nodes, scoreCtx)
+		byPlugin[s.name] = costs
+		for _, n := range nodes {
+			if n == nil {
+				continue
+			}
+			final[n.Name] += s.weight * costs[n.Name]
+		}
+	}
+	return final, byPlugin
 }
 
 func (c *ComposableScheduler) Pick(scores map[string]float64, candidates []*node.Node) *node.Node {
@@ -110,18 +220,26 @@ func (roundRobinResourceFilter) Filter(t task.Task, nodes []*node.Node) []*node.
 	return nodes
 }
 
+func (roundRobinResourceFilter) rejectReason(t task.Task, n *node.Node) string {
+	return ""
+}
+
 type epvmResourceFilter struct{}
 
-func (epvmResourceFilter) Filter(t task.Task, nodes []*node.Node) []*node.Node {
+func (f epvmResourceFilter) Filter(t task.Task, nodes []*node.Node) []*node.Node {
 	var candidates []*node.Node
 	for i := range nodes {
-		if checkDisk(t, nodes[i].Disk-int64(nodes[i].DiskAllocated)) {
+		if f.rejectReason(t, nodes[i]) == "" {
 			candidates = append(candidates, nodes[i])
 		}
 	}
 	return candidates
 }
 
+func (epvmResourceFilter) rejectReason(t task.Task, n *node.Node) string {
+	return diskRejectReason(t, n)
+}
+
 type roundRobinResourceScorer struct {
 	mu         sync.Mutex
 	lastWorker int
@@ -158,6 +276,30 @@ func (r *roundRobinResourceScorer) Score(t task.Task, nodes []*node.Node) map[st
 	return nodeScores
 }
 
+// scoreDryRun scores like Score would next, without moving the cursor.
+func (r *roundRobinResourceScorer) scoreDryRun(t task.Task, nodes []*node.Node) map[string]float64 {
+	nodeScores := make(map[string]float64)
+	if len(nodes) == 0 {
+		return nodeScores
+	}
+
+	r.mu.Lock()
+	newWorker := 0
+	if r.lastWorker+1 < len(nodes) {
+		newWorker = r.lastWorker + 1
+	}
+	r.mu.Unlock()
+
+	for idx, n := range nodes {
+		if idx == newWorker {
+			nodeScores[n.Name] = 0.1
+		} else {
+			nodeScores[n.Name] = 1.0
+		}
+	}
+	return nodeScores
+}
+
 type epvmResourceScorer struct{}
 
 func (epvmResourceScorer) Score(t task.Task, nodes []*node.Node) map[string]float64 {
@@ -167,16 +309,28 @@ func (epvmResourceScorer) Score(t task.Task, nodes []*node.Node) map[string]floa
 	return legacy.rawResourceScores(t, nodes)
 }
 
-type networkFilterPlugin struct{}
+// networkFilterPlugin rejects nodes whose links to dependencies exceed the
+// edge's MaxNetworkCost, judged by the configured latency statistic.
+type networkFilterPlugin struct {
+	statistic topology.LatencyStatistic
+}
 
-func (networkFilterPlugin) Filter(t task.Task, nodes []*node.Node, filterCtx *FilterContext) []*node.Node {
-	return filterNodesByNetworkConstraints(t, nodes, filterCtx)
+func (p networkFilterPlugin) Filter(t task.Task, nodes []*node.Node, filterCtx *FilterContext) []*node.Node {
+	return filterNodesByNetworkConstraints(t, nodes, filterCtx, p.statistic)
 }
 
-type networkScorePlugin struct{}
+func (p networkFilterPlugin) rejectReason(t task.Task, n *node.Node, filterCtx *FilterContext) string {
+	return networkRejectReason(t, n, filterCtx, p.statistic)
+}
+
+// networkScorePlugin blends dependency latency, read with the configured
+// statistic, into the resource scores.
+type networkScorePlugin struct {
+	statistic topology.LatencyStatistic
+}
 
-func (networkScorePlugin) Score(t task.Task, nodes []*node.Node, resourceScores map[string]float64, scoreCtx *ScoreContext) map[string]float64 {
-	return applyNetworkAwareScore(t, nodes, resourceScores, scoreCtx)
+func (p networkScorePlugin) Score(t task.Task, nodes []*node.Node, resourceScores map[string]float64, scoreCtx *ScoreContext) map[string]float64 {
+	return applyNetworkAwareScore(t, nodes, resourceScores, scoreCtx, p.statistic)
 }
 
 type lowestScoreSelector struct{}
@@ -0,0 +1,301 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfileName is the profile used by tasks that do not name one.
+const DefaultProfileName = "default"
+
+// ProfileConfig is the scheduler profile file passed to the manager with
+// --scheduler-profiles:
+//
+//	profiles:
+//	  - name: latency-sensitive
+//	    plugins:
+//	      resourceFilter: epvm
+//	      networkFilter:
+//	        name: network
+//	        args: {latencyStatistic: p95}
+//	      resourceScore: epvm
+//	      networkScore: network
+//	      select: lowestScore
+//	    weights:
+//	      network: 0.9
+//	      resource: 0.1
+//
+// Stages left out keep the plugins of the manager's --scheduler type.
+type ProfileConfig struct {
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// Profile names the plugins that run at each pipeline stage and how their
+// scores are weighted.
+type Profile struct {
+	Name    string         `yaml:"name" json:"name"`
+	Plugins ProfilePlugins `yaml:"plugins" json:"plugins"`
+	Weights ProfileWeights `yaml:"weights" json:"weights"`
+}
+
+// ProfilePlugins lists a profile's plugins. Filters and Scores are extra
+// stages from the registry that run after the built-in filter and score
+// stages, in order; leaving them out keeps the default extra stages.
+type ProfilePlugins struct {
+	ResourceFilter PluginRef   `yaml:"resourceFilter,omitempty" json:"resourceFilter,omitempty"`
+	NetworkFilter  PluginRef   `yaml:"networkFilter,omitempty" json:"networkFilter,omitempty"`
+	Filters        []PluginRef `yaml:"filters,omitempty" json:"filters,omitempty"`
+	ResourceScore  PluginRef   `yaml:"resourceScore,omitempty" json:"resourceScore,omitempty"`
+	NetworkScore   PluginRef   `yaml:"networkScore,omitempty" json:"networkScore,omitempty"`
+	Scores         []PluginRef `yaml:"scores,omitempty" json:"scores,omitempty"`
+	Select         PluginRef   `yaml:"select,omitempty" json:"select,omitempty"`
+}
+
+// PluginRef selects a registered plugin. It is written either as a plain
+// plugin name or as a mapping with arguments. Weight only applies to
+// entries of Scores, where it defaults to 1.
+type PluginRef struct {
+	Name   string     `yaml:"name" json:"name"`
+	Weight float64    `yaml:"weight,omitempty" json:"weight,omitempty"`
+	Args   PluginArgs `yaml:"args,omitempty" json:"args,omitempty"`
+}
+
+// UnmarshalYAML accepts both the plain name and the mapping form.
+func (r *PluginRef) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*r = PluginRef{Name: value.Value}
+		return nil
+	}
+	type plain PluginRef
+	var p plain
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*r = PluginRef(p)
+	return nil
+}
+
+// ProfileWeights balance the network and resource scores. Unset weights
+// keep the defaults (0.7 network, 0.3 resource); the pair is normalised to
+// sum to 1.
+type ProfileWeights struct {
+	Network  *float64 `yaml:"network,omitempty" json:"network,omitempty"`
+	Resource *float64 `yaml:"resource,omitempty" json:"resource,omitempty"`
+}
+
+// DefaultProfile is the profile equivalent to the given --scheduler type.
+func DefaultProfile(schedulerType string) Profile {
+	p := Profile{
+		Name: DefaultProfileName,
+		Plugins: ProfilePlugins{
+			ResourceFilter: PluginRef{Name: "none"},
+			NetworkFilter:  PluginRef{Name: "network"},
+			ResourceScore:  PluginRef{Name: "roundrobin"},
+			NetworkScore:   PluginRef{Name: "network"},
+			Select:         PluginRef{Name: "lowestScore"},
+			Filters: []PluginRef{
+				{Name: "nodeAffinity"},
+				{Name: "serviceAffinity"},
+				{Name: "topologySpread"},
+				{Name: "taintToleration"},
+			},
+			Scores: []PluginRef{
+				{Name: "nodeAffinity", Weight: 1},
+				{Name: "serviceAffinity", Weight: 1},
+				{Name: "topologySpread", Weight: 1},
+				{Name: "taintToleration", Weight: 1},
+			},
+		},
+	}
+	switch schedulerType {
+	case "epvm":
+		p.Plugins.ResourceFilter = PluginRef{Name: "epvm"}
+		p.Plugins.ResourceScore = PluginRef{Name: "epvm"}
+	case "binpack":
+		p.Plugins.ResourceFilter = PluginRef{Name: "binpack"}
+		p.Plugins.ResourceScore = PluginRef{Name: "binpack"}
+	}
+	return p
+}
+
+// withDefaults fills the stages p leaves out from base.
+func (p Profile) withDefaults(base Profile) Profile {
+	if p.Plugins.ResourceFilter.Name == "" {
+		p.Plugins.ResourceFilter = base.Plugins.ResourceFilter
+	}
+	if p.Plugins.NetworkFilter.Name == "" {
+		p.Plugins.NetworkFilter = base.Plugins.NetworkFilter
+	}
+	if p.Plugins.ResourceScore.Name == "" {
+		p.Plugins.ResourceScore = base.Plugins.ResourceScore
+	}
+	if p.Plugins.NetworkScore.Name == "" {
+		p.Plugins.NetworkScore = base.Plugins.NetworkScore
+	}
+	if p.Plugins.Select.Name == "" {
+		p.Plugins.Select = base.Plugins.Select
+	}
+	// An explicit empty list (filters: []) turns the extra stages off.
+	if p.Plugins.Filters == nil {
+		p.Plugins.Filters = base.Plugins.Filters
+	}
+	if p.Plugins.Scores == nil {
+		p.Plugins.Scores = base.Plugins.Scores
+	}
+	return p
+}
+
+func (p Profile) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile without a name")
+	}
+	w := p.Weights
+	if (w.Network != nil && *w.Network < 0) || (w.Resource != nil && *w.Resource < 0) {
+		return fmt.Errorf("profile %q: weights must not be negative", p.Name)
+	}
+	if w.Network != nil && w.Resource != nil && *w.Network+*w.Resource == 0 {
+		return fmt.Errorf("profile %q: network and resource weights cannot both be zero", p.Name)
+	}
+	for _, ref := range p.Plugins.Filters {
+		if ref.Name == "" {
+			return fmt.Errorf("profile %q: filters entry without a plugin name", p.Name)
+		}
+	}
+	for _, ref := range p.Plugins.Scores {
+		if ref.Name == "" {
+			return fmt.Errorf("profile %q: scores entry without a plugin name", p.Name)
+		}
+		if ref.Weight < 0 {
+			return fmt.Errorf("profile %q: score plugin %q has a negative weight", p.Name, ref.Name)
+		}
+	}
+	return nil
+}
+
+// LoadProfiles reads and validates a scheduler profile file.
+func LoadProfiles(path string) ([]Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading scheduler profiles: %w", err)
+	}
+	return ParseProfiles(data)
+}
+
+// ParseProfiles parses and validates scheduler profiles from YAML.
+func ParseProfiles(data []byte) ([]Profile, error) {
+	var cfg ProfileConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing scheduler profiles YAML: %w", err)
+	}
+
+	seen := make(map[string]bool, len(cfg.Profiles))
+	for _, p := range cfg.Profiles {
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("profile %q defined twice", p.Name)
+		}
+		seen[p.Name] = true
+		// Build once so unknown plugins and bad arguments are reported
+		// when the file is loaded rather than when a task needs them.
+		if _, err := NewProfileScheduler(p, "", ""); err != nil {
+			return nil, err
+		}
+	}
+	return cfg.Profiles, nil
+}
+
+// BuildProfiles builds a pipeline for each profile, plus the default
+// profile for schedulerType unless profiles redefine it. The result is keyed
+// by profile name.
+func BuildProfiles(profiles []Profile, schedulerType, queueSortStrategy string, opts ...PipelineOption) (map[string]*ComposableScheduler, error) {
+	built := make(map[string]*ComposableScheduler, len(profiles)+1)
+	def, err := NewProfileScheduler(DefaultProfile(schedulerType), schedulerType, queueSortStrategy, opts...)
+	if err != nil {
+		return nil, err
+	}
+	built[DefaultProfileName] = def
+	for _, p := range profiles {
+		c, err := NewProfileScheduler(p, schedulerType, queueSortStrategy, opts...)
+		if err != nil {
+			return nil, err
+		}
+		built[p.Name] = c
+	}
+	return built, nil
+}
+
+// NewProfileScheduler builds a pipeline from the registered plugins a
+// profile names. Stages the profile leaves out use the plugins of
+// DefaultProfile(schedulerType).
+func NewProfileScheduler(p Profile, schedulerType, queueSortStrategy string, opts ...PipelineOption) (*ComposableScheduler, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	p = p.withDefaults(DefaultProfile(schedulerType))
+
+	options := pipelineOptions{
+		filterLatency: defaultFilterLatencyStatistic,
+		scoreLatency:  defaultScoreLatencyStatistic,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	bc := BuildContext{
+		FilterLatency:  options.filterLatency,
+		ScoreLatency:   options.scoreLatency,
+		BinpackWeights: options.binpackWeights,
+	}
+
+	c := &ComposableScheduler{
+		profile:        p.Name,
+		queueSorter:    NewQueueSorter(queueSortStrategy),
+		networkWeight:  defaultNetworkWeight,
+		resourceWeight: defaultResourceWeight,
+	}
+	if p.Weights.Network != nil {
+		c.networkWeight = *p.Weights.Network
+	}
+	if p.Weights.Resource != nil {
+		c.resourceWeight = *p.Weights.Resource
+	}
+
+	var err error
+	if c.resourceFilter, err = resourceFilters.build(p.Plugins.ResourceFilter.Name, p.Plugins.ResourceFilter.Args, bc); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	if c.networkFilter, err = networkFilters.build(p.Plugins.NetworkFilter.Name, p.Plugins.NetworkFilter.Args, bc); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	for _, ref := range p.Plugins.Filters {
+		f, err := filters.build(ref.Name, ref.Args, bc)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+		c.filters = append(c.filters, namedFilter{name: ref.Name, plugin: f})
+	}
+	if c.resourceScore, err = resourceScores.build(p.Plugins.ResourceScore.Name, p.Plugins.ResourceScore.Args, bc); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	if c.networkScore, err = networkScores.build(p.Plugins.NetworkScore.Name, p.Plugins.NetworkScore.Args, bc); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	for _, ref := range p.Plugins.Scores {
+		s, err := scores.build(ref.Name, ref.Args, bc)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+		weight := ref.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		c.scores = append(c.scores, weightedScore{name: ref.Name, weight: weight, plugin: s})
+	}
+	if c.finalSelect, err = selectors.build(p.Plugins.Select.Name, p.Plugins.Select.Args, bc); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	return c, nil
+}
@@ -0,0 +1,225 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/aditip149209/okube/pkg/node"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/aditip149209/okube/pkg/topology"
+)
+
+// FilterPlugin is an additional filter stage that runs after the resource
+// and network filters. It judges one node at a time so every rejection
+// carries a reason.
+type FilterPlugin interface {
+	// Reject returns why t cannot run on n, or "" if it can.
+	Reject(t task.Task, n *node.Node, filterCtx *FilterContext) string
+}
+
+// ScorePlugin is an additional score stage. Its scores are costs in [0, 1]
+// (lower is better) that are multiplied by the plugin's weight and added to
+// the network-aware score, itself rescaled to [0, 1] across the nodes.
+type ScorePlugin interface {
+	Score(t task.Task, nodes []*node.Node, scoreCtx *ScoreContext) map[string]float64
+}
+
+// PluginArgs are the parameters a profile passes to a plugin.
+type PluginArgs map[string]interface{}
+
+// String returns the string argument key, or def when it is absent.
+func (a PluginArgs) String(key, def string) string {
+	v, ok := a[key]
+	if !ok || v == nil {
+		return def
+	}
+	return fmt.Sprint(v)
+}
+
+// Float returns the numeric argument key, or def when it is absent.
+func (a PluginArgs) Float(key string, def float64) (float64, error) {
+	v, ok := a[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, fmt.Errorf("argument %q: %w", key, err)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("argument %q: expected a number, got %T", key, v)
+	}
+}
+
+// BuildContext carries manager-wide defaults that plugins fall back to when
+// their arguments leave a setting out.
+type BuildContext struct {
+	FilterLatency  topology.LatencyStatistic
+	ScoreLatency   topology.LatencyStatistic
+	BinpackWeights BinpackWeights
+}
+
+// PluginFactory builds a plugin from its profile arguments.
+type PluginFactory[P any] func(args PluginArgs, bc BuildContext) (P, error)
+
+type pluginRegistry[P any] struct {
+	stage     string
+	mu        sync.RWMutex
+	factories map[string]PluginFactory[P]
+}
+
+func newPluginRegistry[P any](stage string) *pluginRegistry[P] {
+	return &pluginRegistry[P]{stage: stage, factories: make(map[string]PluginFactory[P])}
+}
+
+func (r *pluginRegistry[P]) register(name string, factory PluginFactory[P]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if factory == nil {
+		panic(fmt.Sprintf("scheduler: nil factory for %s plugin %q", r.stage, name))
+	}
+	if _, dup := r.factories[name]; dup {
+		panic(fmt.Sprintf("scheduler: %s plugin %q registered twice", r.stage, name))
+	}
+	r.factories[name] = factory
+}
+
+func (r *pluginRegistry[P]) build(name string, args PluginArgs, bc BuildContext) (P, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+	if !ok {
+		var zero P
+		return zero, fmt.Errorf("unknown %s plugin %q (known: %v)", r.stage, name, r.names())
+	}
+	p, err := factory(args, bc)
+	if err != nil {
+		return p, fmt.Errorf("%s plugin %q: %w", r.stage, name, err)
+	}
+	return p, nil
+}
+
+func (r *pluginRegistry[P]) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	resourceFilters = newPluginRegistry[ResourceFilterPlugin]("resourceFilter")
+	networkFilters  = newPluginRegistry[NetworkFilterPlugin]("networkFilter")
+	filters         = newPluginRegistry[FilterPlugin]("filter")
+	resourceScores  = newPluginRegistry[ResourceScorePlugin]("resourceScore")
+	networkScores   = newPluginRegistry[NetworkScorePlugin]("networkScore")
+	scores          = newPluginRegistry[ScorePlugin]("score")
+	selectors       = newPluginRegistry[FinalSelectionPlugin]("select")
+)
+
+// RegisterResourceFilter makes a resource filter available to profiles
+// under name. It panics if the name is taken.
+func RegisterResourceFilter(name string, factory PluginFactory[ResourceFilterPlugin]) {
+	resourceFilters.register(name, factory)
+}
+
+// RegisterNetworkFilter makes a network filter available to profiles.
+func RegisterNetworkFilter(name string, factory PluginFactory[NetworkFilterPlugin]) {
+	networkFilters.register(name, factory)
+}
+
+// RegisterFilter makes an additional filter available to profiles.
+func RegisterFilter(name string, factory PluginFactory[FilterPlugin]) {
+	filters.register(name, factory)
+}
+
+// RegisterResourceScore makes a resource scorer available to profiles.
+func RegisterResourceScore(name string, factory PluginFactory[ResourceScorePlugin]) {
+	resourceScores.register(name, factory)
+}
+
+// RegisterNetworkScore makes a network scorer available to profiles.
+func RegisterNetworkScore(name string, factory PluginFactory[NetworkScorePlugin]) {
+	networkScores.register(name, factory)
+}
+
+// RegisterScore makes an additional weighted scorer available to profiles.
+func RegisterScore(name string, factory PluginFactory[ScorePlugin]) {
+	scores.register(name, factory)
+}
+
+// RegisterSelector makes a final selection plugin available to profiles.
+func RegisterSelector(name string, factory PluginFactory[FinalSelectionPlugin]) {
+	selectors.register(name, factory)
+}
+
+// passNetworkFilter accepts every node.
+type passNetworkFilter struct{}
+
+func (passNetworkFilter) Filter(t task.Task, nodes []*node.Node, filterCtx *FilterContext) []*node.Node {
+	return nodes
+}
+
+// latencyStatisticArg reads the optional latencyStatistic argument.
+func latencyStatisticArg(args PluginArgs, def topology.LatencyStatistic) (topology.LatencyStatistic, error) {
+	raw := args.String("latencyStatistic", "")
+	if raw == "" {
+		return def, nil
+	}
+	stat := topology.ParseLatencyStatistic(raw, "")
+	if stat == "" {
+		return "", fmt.Errorf("unknown latencyStatistic %q (expected latest, ewma, p50 or p95)", raw)
+	}
+	return stat, nil
+}
+
+func init() {
+	RegisterResourceFilter("none", func(PluginArgs, BuildContext) (ResourceFilterPlugin, error) {
+		return roundRobinResourceFilter{}, nil
+	})
+	RegisterResourceFilter("epvm", func(PluginArgs, BuildContext) (ResourceFilterPlugin, error) {
+		return epvmResourceFilter{}, nil
+	})
+
+	RegisterNetworkFilter("none", func(PluginArgs, BuildContext) (NetworkFilterPlugin, error) {
+		return passNetworkFilter{}, nil
+	})
+	RegisterNetworkFilter("network", func(args PluginArgs, bc BuildContext) (NetworkFilterPlugin, error) {
+		stat, err := latencyStatisticArg(args, bc.FilterLatency)
+		if err != nil {
+			return nil, err
+		}
+		return networkFilterPlugin{statistic: stat}, nil
+	})
+
+	RegisterResourceScore("roundrobin", func(PluginArgs, BuildContext) (ResourceScorePlugin, error) {
+		return newRoundRobinResourceScorer(), nil
+	})
+	RegisterResourceScore("epvm", func(PluginArgs, BuildContext) (ResourceScorePlugin, error) {
+		return epvmResourceScorer{}, nil
+	})
+
+	RegisterNetworkScore("network", func(args PluginArgs, bc BuildContext) (NetworkScorePlugin, error) {
+		stat, err := latencyStatisticArg(args, bc.ScoreLatency)
+		if err != nil {
+			return nil, err
+		}
+		return networkScorePlugin{statistic: stat}, nil
+	})
+
+	RegisterSelector("lowestScore", func(PluginArgs, BuildContext) (FinalSelectionPlugin, error) {
+		return lowestScoreSelector{}, nil
+	})
+}
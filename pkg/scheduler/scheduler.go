@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"time"
 
 	"github.com/aditip149209/okube/pkg/appgroup"
 	"github.com/aditip149209/okube/pkg/node"
@@ -29,6 +28,18 @@ type FilterContext struct {
 	AppGroup                *appgroup.AppGroup
 	NetworkTopology         *topology.NetworkTopology
 	DependencyNodeByService map[string]string
+	// Placements are the other Scheduled or Running tasks of every app and
+	// the node each holds.
+	Placements []TaskPlacement
+	// Nodes are every schedulable node, so plugins that compare topology
+	// domains also see domains that hold no tasks yet.
+	Nodes []*node.Node
+}
+
+// TaskPlacement is a placed task and the node it runs on.
+type TaskPlacement struct {
+	Task *task.Task
+	Node string
 }
 
 // ScoreContext carries optional metadata and weights for combining network
@@ -37,6 +48,9 @@ type ScoreContext struct {
 	Filter         *FilterContext
 	NetworkWeight  float64
 	ResourceWeight float64
+	// ExplicitWeights makes a zero weight mean zero rather than "use the
+	// default", as scheduler profiles may ask for.
+	ExplicitWeights bool
 }
 
 type RoundRobin struct {
@@ -45,7 +59,7 @@ type RoundRobin struct {
 }
 
 func (r *RoundRobin) SelectCandidateNodes(t task.Task, nodes []*node.Node, filterCtx *FilterContext) []*node.Node {
-	return filterNodesByNetworkConstraints(t, nodes, filterCtx)
+	return filterNodesByNetworkConstraints(t, nodes, filterCtx, defaultFilterLatencyStatistic)
 }
 
 func (r *RoundRobin) Score(t task.Task, nodes []*node.Node, scoreCtx *ScoreContext) map[string]float64 {
@@ -67,7 +81,7 @@ func (r *RoundRobin) Score(t task.Task, nodes []*node.Node, scoreCtx *ScoreConte
 		}
 	}
 
-	return applyNetworkAwareScore(t, nodes, nodeScores, scoreCtx)
+	return applyNetworkAwareScore(t, nodes, nodeScores, scoreCtx, defaultScoreLatencyStatistic)
 }
 
 func (r *RoundRobin) Pick(scores map[string]float64, candidates []*node.Node) *node.Node {
@@ -103,54 +117,27 @@ func (e *Epvm) SelectCandidateNodes(t task.Task, nodes []*node.Node, filterCtx *
 			candidates = append(candidates, nodes[node])
 		}
 	}
-	return filterNodesByNetworkConstraints(t, candidates, filterCtx)
+	return filterNodesByNetworkConstraints(t, candidates, filterCtx, defaultFilterLatencyStatistic)
 
 }
 
+// calculateCpuUsage returns the node's current CPU utilisation (0-1). The
+// worker derives it from the delta between consecutive /proc/stat samples, so
+// a single stats snapshot is enough; nodes from the manager's inventory
+// already carry one.
 func calculateCpuUsage(node *node.Node) (*float64, error) {
-	stat1, err1 := node.GetNodeStats()
-	if err1 != nil {
-		msg := fmt.Sprintf("There was an error in calculateCpuUsage: %v", err1)
+	stats, err := node.CurrentStats()
+	if err != nil {
+		msg := fmt.Sprintf("There was an error in calculateCpuUsage: %v", err)
 		log.Println(msg)
 		return nil, errors.New(msg)
 	}
-	if stat1 == nil || stat1.CpuStats == nil {
+	if stats == nil || stats.CpuStats == nil {
 		return nil, errors.New("cpu stats unavailable for node")
 	}
-	time.Sleep(3 * time.Second)
-	stat2, err2 := node.GetNodeStats()
-
-	if err2 != nil {
-		msg := fmt.Sprintf("There was an error in calculateCPUusage part2: %v", err2)
-		log.Println(msg)
-		return nil, errors.New(msg)
-	}
-	if stat2 == nil || stat2.CpuStats == nil {
-		return nil, errors.New("cpu stats unavailable for node (second sample)")
-	}
-
-	stat1Idle := stat1.CpuStats.Idle + stat1.CpuStats.IOWait
-	stat2Idle := stat2.CpuStats.Idle + stat2.CpuStats.IOWait
-
-	stat1NonIdle := stat1.CpuStats.User + stat1.CpuStats.Nice + stat1.CpuStats.System + stat1.CpuStats.IRQ + stat1.CpuStats.SoftIRQ + stat1.CpuStats.Steal
-
-	stat2NonIdle := stat2.CpuStats.User + stat2.CpuStats.Nice + stat2.CpuStats.System + stat2.CpuStats.IRQ + stat2.CpuStats.SoftIRQ + stat2.CpuStats.Steal
-
-	stat1Total := stat1Idle + stat1NonIdle
-	stat2Total := stat2Idle + stat2NonIdle
-
-	total := stat2Total - stat1Total
-	idle := stat2Idle - stat1Idle
-
-	var cpuPercentUsage float64
-	if total == 0 && idle == 0 {
-		cpuPercentUsage = 0
-	} else {
-		cpuPercentUsage = (float64(total) - float64(idle)) / float64(total)
-	}
 
+	cpuPercentUsage := stats.CpuUsage()
 	return &cpuPercentUsage, nil
-
 }
 
 func calculateLoad(val float64, max float64) float64 {
@@ -162,7 +149,7 @@ func calculateLoad(val float64, max float64) float64 {
 
 func (e *Epvm) Score(t task.Task, nodes []*node.Node, scoreCtx *ScoreContext) map[string]float64 {
 	nodeScores := e.rawResourceScores(t, nodes)
-	return applyNetworkAwareScore(t, nodes, nodeScores, scoreCtx)
+	return applyNetworkAwareScore(t, nodes, nodeScores, scoreCtx, defaultScoreLatencyStatistic)
 
 }
 
@@ -181,7 +168,7 @@ func (e *Epvm) rawResourceScores(t task.Task, nodes []*node.Node) map[string]flo
 		}
 		cpuLoad := calculateLoad(*cpuUsage, math.Pow(2, 0.8))
 
-		stats, err := node.GetNodeStats()
+		stats, err := node.CurrentStats()
 		if err != nil {
 			log.Printf("Warning: Could not fetch stats for node %s: %v. Using default high score.", node.Name, err)
 			nodeScores[node.Name] = 1000.0 // High cost = low priority
@@ -192,7 +179,9 @@ func (e *Epvm) rawResourceScores(t task.Task, nodes []*node.Node) map[string]flo
 			nodeScores[node.Name] = 1000.0
 			continue
 		}
-		memoryAllocated := float64(stats.MemUsedKb()) + float64(node.MemoryAllocated)
+		// Measured usage already includes every started task, so only the
+		// memory of tasks that have not started yet is added.
+		memoryAllocated := float64(stats.MemUsedKb()) + float64(node.MemoryInFlight)
 		if node.Memory == 0 {
 			log.Printf("Warning: Node %s reports zero memory. Using default high score.", node.Name)
 			nodeScores[node.Name] = 1000.0
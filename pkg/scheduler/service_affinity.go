@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aditip149209/okube/pkg/node"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/aditip149209/okube/pkg/topology"
+)
+
+// topologyDomain returns the domain nodeID belongs to under a topology key
+// (node, zone or region). A node without a zone or region mapping forms a
+// domain of its own.
+func topologyDomain(nt *topology.NetworkTopology, nodeID, key string) string {
+	switch key {
+	case task.TopologyKeyZone:
+		if zone, ok := nt.GetZone(nodeID); ok && zone != "" {
+			return "zone/" + zone
+		}
+	case task.TopologyKeyRegion:
+		if region, ok := nt.GetRegion(nodeID); ok && region != "" {
+			return "region/" + region
+		}
+	}
+	return "node/" + nodeID
+}
+
+func topologyKeyOrNode(key string) string {
+	if key == "" {
+		return task.TopologyKeyNode
+	}
+	return key
+}
+
+// affinityTermState reports whether a task the term selects runs in the
+// domain of nodeID, and whether the term selects any placed task at all.
+func affinityTermState(t task.Task, nodeID string, term task.ServiceAffinityTerm, filterCtx *FilterContext) (inDomain, anywhere bool) {
+	if filterCtx == nil {
+		return false, false
+	}
+	domain := topologyDomain(filterCtx.NetworkTopology, nodeID, term.TopologyKey)
+	for _, p := range filterCtx.Placements {
+		if !term.Selects(t.AppID, p.Task) {
+			continue
+		}
+		anywhere = true
+		if topologyDomain(filterCtx.NetworkTopology, p.Node, term.TopologyKey) == domain {
+			return true, true
+		}
+	}
+	return false, anywhere
+}
+
+// describeAffinityTerm names what a term selects, for reject reasons.
+func describeAffinityTerm(term task.ServiceAffinityTerm) string {
+	var parts []string
+	if len(term.Services) > 0 {
+		parts = append(parts, "service "+strings.Join(term.Services, "/"))
+	}
+	if len(term.MatchLabels) > 0 {
+		keys := make([]string, 0, len(term.MatchLabels))
+		for k := range term.MatchLabels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		labels := make([]string, 0, len(keys))
+		for _, k := range keys {
+			labels = append(labels, k+"="+term.MatchLabels[k])
+		}
+		parts = append(parts, "tasks labelled "+strings.Join(labels, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// serviceAffinityFilter enforces required affinity and anti-affinity
+// between tasks. A required affinity term that selects no placed task
+// anywhere is satisfied, so the first of a group can be placed.
+type serviceAffinityFilter struct{}
+
+func (serviceAffinityFilter) Reject(t task.Task, n *node.Node, filterCtx *FilterContext) string {
+	if t.Affinity != nil {
+		for _, term := range t.Affinity.Required {
+			inDomain, anywhere := affinityTermState(t, n.Name, term, filterCtx)
+			if anywhere && !inDomain {
+				return fmt.Sprintf("affinity: no %s in the same %s", describeAffinityTerm(term), topologyKeyOrNode(term.TopologyKey))
+			}
+		}
+	}
+	if t.AntiAffinity != nil {
+		for _, term := range t.AntiAffinity.Required {
+			if inDomain, _ := affinityTermState(t, n.Name, term, filterCtx); inDomain {
+				return fmt.Sprintf("anti-affinity: %s already in the same %s", describeAffinityTerm(term), topologyKeyOrNode(term.TopologyKey))
+			}
+		}
+	}
+	return ""
+}
+
+// serviceAffinityScorer weighs preferred affinity and anti-affinity terms.
+// A node's cost is the share of preference weight it misses: affinity
+// terms with no selected task in its domain (while one exists elsewhere)
+// and anti-affinity terms with one.
+type serviceAffinityScorer struct{}
+
+func (serviceAffinityScorer) Score(t task.Task, nodes []*node.Node, scoreCtx *ScoreContext) map[string]float64 {
+	var filterCtx *FilterContext
+	if scoreCtx != nil {
+		filterCtx = scoreCtx.Filter
+	}
+
+	var affinity, anti []task.WeightedServiceAffinityTerm
+	if t.Affinity != nil {
+		affinity = t.Affinity.Preferred
+	}
+	if t.AntiAffinity != nil {
+		anti = t.AntiAffinity.Preferred
+	}
+	total := 0
+	for _, pref := range affinity {
+		total += pref.Weight
+	}
+	for _, pref := range anti {
+		total += pref.Weight
+	}
+
+	costs := make(map[string]float64, len(nodes))
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		if total == 0 {
+			costs[n.Name] = 0
+			continue
+		}
+		missed := 0
+		for _, pref := range affinity {
+			if inDomain, anywhere := affinityTermState(t, n.Name, pref.ServiceAffinityTerm, filterCtx); anywhere && !inDomain {
+				missed += pref.Weight
+			}
+		}
+		for _, pref := range anti {
+			if inDomain, _ := affinityTermState(t, n.Name, pref.ServiceAffinityTerm, filterCtx); inDomain {
+				missed += pref.Weight
+			}
+		}
+		costs[n.Name] = float64(missed) / float64(total)
+	}
+	return costs
+}
+
+func init() {
+	RegisterFilter("serviceAffinity", func(PluginArgs, BuildContext) (FilterPlugin, error) {
+		return serviceAffinityFilter{}, nil
+	})
+	RegisterScore("serviceAffinity", func(PluginArgs, BuildContext) (ScorePlugin, error) {
+		return serviceAffinityScorer{}, nil
+	})
+}
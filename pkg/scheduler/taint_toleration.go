@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/aditip149209/okube/pkg/node"
+	"github.com/aditip149209/okube/pkg/task"
+)
+
+// taintTolerationFilter rejects nodes with a NoSchedule or NoExecute taint
+// the task does not tolerate.
+type taintTolerationFilter struct{}
+
+func (taintTolerationFilter) Reject(t task.Task, n *node.Node, filterCtx *FilterContext) string {
+	for _, taint := range n.Taints {
+		if taint.Effect == task.TaintPreferNoSchedule {
+			continue
+		}
+		if !t.ToleratesTaint(taint) {
+			return fmt.Sprintf("taint %s not tolerated", taint)
+		}
+	}
+	return ""
+}
+
+// taintTolerationScorer steers tasks away from nodes with PreferNoSchedule
+// taints they do not tolerate. The cost is the share of such taints among
+// those of the node with the most, so untainted nodes cost 0.
+type taintTolerationScorer struct{}
+
+func (taintTolerationScorer) Score(t task.Task, nodes []*node.Node, scoreCtx *ScoreContext) map[string]float64 {
+	untolerated := make(map[string]int, len(nodes))
+	most := 0
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		count := 0
+		for _, taint := range n.Taints {
+			if taint.Effect == task.TaintPreferNoSchedule && !t.ToleratesTaint(taint) {
+				count++
+			}
+		}
+		untolerated[n.Name] = count
+		if count > most {
+			most = count
+		}
+	}
+
+	costs := make(map[string]float64, len(untolerated))
+	for name, count := range untolerated {
+		if most == 0 {
+			costs[name] = 0
+			continue
+		}
+		costs[name] = float64(count) / float64(most)
+	}
+	return costs
+}
+
+func init() {
+	RegisterFilter("taintToleration", func(PluginArgs, BuildContext) (FilterPlugin, error) {
+		return taintTolerationFilter{}, nil
+	})
+	RegisterScore("taintToleration", func(PluginArgs, BuildContext) (ScorePlugin, error) {
+		return taintTolerationScorer{}, nil
+	})
+}
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/aditip149209/okube/pkg/node"
+	"github.com/aditip149209/okube/pkg/task"
+	"github.com/aditip149209/okube/pkg/topology"
+)
+
+// spreadCounts counts the placed tasks of t's service per domain of key.
+// The domain of every schedulable node t's node selector, node affinity and
+// taint tolerations allow is present, with zero if it holds none; domains t
+// could never use would otherwise pin the lowest count at zero.
+func spreadCounts(t task.Task, key string, filterCtx *FilterContext) map[string]int {
+	counts := make(map[string]int)
+	if filterCtx == nil {
+		return counts
+	}
+	for _, n := range filterCtx.Nodes {
+		if n == nil || (nodeAffinityFilter{}).Reject(t, n, filterCtx) != "" || (taintTolerationFilter{}).Reject(t, n, filterCtx) != "" {
+			continue
+		}
+		counts[topologyDomain(filterCtx.NetworkTopology, n.Name, key)] = 0
+	}
+
+	serviceID := serviceIDForTask(&t)
+	for _, p := range filterCtx.Placements {
+		if p.Task == nil || p.Task.AppID != t.AppID || serviceIDForTask(p.Task) != serviceID {
+			continue
+		}
+		counts[topologyDomain(filterCtx.NetworkTopology, p.Node, key)]++
+	}
+	return counts
+}
+
+// spreadSkew is the skew of placing one more task in domain: its count
+// after placement minus the lowest count of any domain before it.
+func spreadSkew(counts map[string]int, domain string) int {
+	lowest := counts[domain]
+	for _, c := range counts {
+		if c < lowest {
+			lowest = c
+		}
+	}
+	return counts[domain] + 1 - lowest
+}
+
+func networkTopologyOf(filterCtx *FilterContext) *topology.NetworkTopology {
+	if filterCtx == nil {
+		return nil
+	}
+	return filterCtx.NetworkTopology
+}
+
+// topologySpreadFilter rejects nodes where placing the task would push a
+// reject-mode spread constraint past its maxSkew.
+type topologySpreadFilter struct{}
+
+func (topologySpreadFilter) Reject(t task.Task, n *node.Node, filterCtx *FilterContext) string {
+	for _, c := range t.SpreadConstraints {
+		if c.Prefer() {
+			continue
+		}
+		key := topologyKeyOrNode(c.TopologyKey)
+		counts := spreadCounts(t, key, filterCtx)
+		domain := topologyDomain(networkTopologyOf(filterCtx), n.Name, key)
+		if skew := spreadSkew(counts, domain); skew > c.MaxSkew {
+			return fmt.Sprintf("spread: %d task(s) of the service already in %s, skew would be %d, above maxSkew %d", counts[domain], domain, skew, c.MaxSkew)
+		}
+	}
+	return ""
+}
+
+// topologySpreadScorer prefers the least crowded domains for prefer-mode
+// constraints. Per constraint a node costs its domain's count relative to
+// the emptiest and fullest domains; costs are averaged over constraints.
+type topologySpreadScorer struct{}
+
+func (topologySpreadScorer) Score(t task.Task, nodes []*node.Node, scoreCtx *ScoreContext) map[string]float64 {
+	var filterCtx *FilterContext
+	if scoreCtx != nil {
+		filterCtx = scoreCtx.Filter
+	}
+
+	costs := make(map[string]float64, len(nodes))
+	constraints := 0
+	for _, c := range t.SpreadConstraints {
+		if !c.Prefer() {
+			continue
+		}
+		constraints++
+		key := topologyKeyOrNode(c.TopologyKey)
+		counts := spreadCounts(t, key, filterCtx)
+
+		domains := make(map[string]string, len(nodes))
+		lowest, highest := -1, 0
+		for _, n := range nodes {
+			if n == nil {
+				continue
+			}
+			d := topologyDomain(networkTopologyOf(filterCtx), n.Name, key)
+			domains[n.Name] = d
+			if lowest < 0 || counts[d] < lowest {
+				lowest = counts[d]
+			}
+			if counts[d] > highest {
+				highest = counts[d]
+			}
+		}
+		if highest <= lowest {
+			continue
+		}
+		for name, d := range domains {
+			costs[name] += float64(counts[d]-lowest) / float64(highest-lowest)
+		}
+	}
+
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		if constraints > 0 {
+			costs[n.Name] /= float64(constraints)
+		} else {
+			costs[n.Name] = 0
+		}
+	}
+	return costs
+}
+
+func init() {
+	RegisterFilter("topologySpread", func(PluginArgs, BuildContext) (FilterPlugin, error) {
+		return topologySpreadFilter{}, nil
+	})
+	RegisterScore("topologySpread", func(PluginArgs, BuildContext) (ScorePlugin, error) {
+		return topologySpreadScorer{}, nil
+	})
+}
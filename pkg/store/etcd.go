@@ -124,6 +124,18 @@ func (e *EtcdStore) networkTopologyKey() string {
 	return fmt.Sprintf("%s/network/topology", e.prefix)
 }
 
+func (e *EtcdStore) topologyOverridesKey() string {
+	return fmt.Sprintf("%s/network/overrides", e.prefix)
+}
+
+func (e *EtcdStore) reservationsPrefix() string {
+	return fmt.Sprintf("%s/network/reservations/", e.prefix)
+}
+
+func (e *EtcdStore) taskReservationsPrefix(taskID string) string {
+	return fmt.Sprintf("%s/network/reservations/%s/", e.prefix, taskID)
+}
+
 // UpdateWorkerHeartbeat persists a new heartbeat timestamp for a worker.
 func (e *EtcdStore) UpdateWorkerHeartbeat(ctx context.Context, workerID string, heartbeat time.Time) error {
 	hbBytes, err := json.Marshal(heartbeat)
@@ -147,20 +159,156 @@ func (e *EtcdStore) UpdateWorkerHeartbeat(ctx context.Context, workerID string,
 	return err
 }
 
+// SetWorkerDraining flags a registered worker as draining (or clears the
+// flag). Draining workers stay visible but are skipped by the scheduler.
+func (e *EtcdStore) SetWorkerDraining(ctx context.Context, workerID string, draining bool) error {
+	resp, err := e.client.Get(ctx, e.workerKey(workerID))
+	if err != nil {
+		return err
+	}
+
+	if resp.Count == 0 {
+		return ErrNotFound
+	}
+
+	var w Worker
+	if err := json.Unmarshal(resp.Kvs[0].Value, &w); err != nil {
+		return err
+	}
+	w.Draining = draining
+
+	workerBytes, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.client.Put(ctx, e.workerKey(workerID), string(workerBytes))
+	return err
+}
+
+// SetWorkerTaints replaces the taints of a registered worker.
+func (e *EtcdStore) SetWorkerTaints(ctx context.Context, workerID string, taints []task.Taint) error {
+	resp, err := e.client.Get(ctx, e.workerKey(workerID))
+	if err != nil {
+		return err
+	}
+
+	if resp.Count == 0 {
+		return ErrNotFound
+	}
+
+	var w Worker
+	if err := json.Unmarshal(resp.Kvs[0].Value, &w); err != nil {
+		return err
+	}
+	w.Taints = taints
+
+	workerBytes, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.client.Put(ctx, e.workerKey(workerID), string(workerBytes))
+	return err
+}
+
+// DeregisterWorker removes a worker's metadata and heartbeat from the store.
+func (e *EtcdStore) DeregisterWorker(ctx context.Context, workerID string) error {
+	_, err := e.client.Txn(ctx).Then(
+		clientv3.OpDelete(e.workerKey(workerID)),
+		clientv3.OpDelete(e.workerHeartbeatKey(workerID)),
+	).Commit()
+	return err
+}
+
 // CreateTask stores a task and its assignment.
 func (e *EtcdStore) CreateTask(ctx context.Context, t *task.Task, workerID string) error {
 	if t == nil {
 		return fmt.Errorf("task cannot be nil")
 	}
 
-	taskBytes, err := json.Marshal(t)
+	op, err := e.createTaskOps(t, workerID)
 	if err != nil {
 		return err
 	}
 
+	_, err = e.client.Txn(ctx).Then(op...).Commit()
+	return err
+}
+
+// maxTxnOps is etcd's default --max-txn-ops, the most operations one
+// transaction may carry.
+const maxTxnOps = 128
+
+// CreateTasks stores several tasks and their assignments so that either
+// every task is recorded or none is. Batches too large for one transaction
+// are written in several; if one fails, the tasks already written are
+// removed again.
+func (e *EtcdStore) CreateTasks(ctx context.Context, records []TaskRecord) error {
+	var written []uuid.UUID
+	var op []clientv3.Op
+	var pending []uuid.UUID
+	flush := func() error {
+		if len(op) == 0 {
+			return nil
+		}
+		if _, err := e.client.Txn(ctx).Then(op...).Commit(); err != nil {
+			return err
+		}
+		written = append(written, pending...)
+		op, pending = nil, nil
+		return nil
+	}
+
+	for _, rec := range records {
+		if rec.Task == nil {
+			return fmt.Errorf("task cannot be nil")
+		}
+	}
+	for _, rec := range records {
+		taskOps, err := e.createTaskOps(rec.Task, rec.WorkerID)
+		if err == nil && len(op)+len(taskOps) > maxTxnOps {
+			err = flush()
+		}
+		if err != nil {
+			e.deleteTasks(ctx, written)
+			return err
+		}
+		op = append(op, taskOps...)
+		pending = append(pending, rec.Task.ID)
+	}
+	if err := flush(); err != nil {
+		e.deleteTasks(ctx, written)
+		return err
+	}
+	return nil
+}
+
+// deleteTasks removes every key of the given tasks, best effort.
+func (e *EtcdStore) deleteTasks(ctx context.Context, ids []uuid.UUID) {
+	var op []clientv3.Op
+	for i, id := range ids {
+		op = append(op,
+			clientv3.OpDelete(e.taskKey(id)),
+			clientv3.OpDelete(e.taskKey(id)+"/", clientv3.WithPrefix()),
+		)
+		if len(op)+2 > maxTxnOps || i == len(ids)-1 {
+			_, _ = e.client.Txn(ctx).Then(op...).Commit()
+			op = nil
+		}
+	}
+}
+
+// createTaskOps returns the writes that record t and its assignment.
+func (e *EtcdStore) createTaskOps(t *task.Task, workerID string) ([]clientv3.Op, error) {
+	taskBytes, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
 	stateBytes, err := json.Marshal(t.State)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	op := []clientv3.Op{
@@ -171,13 +319,38 @@ func (e *EtcdStore) CreateTask(ctx context.Context, t *task.Task, workerID strin
 	if workerID != "" {
 		workerBytes, err := json.Marshal(workerID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		op = append(op, clientv3.OpPut(e.taskWorkerKey(t.ID), string(workerBytes)))
 		op = append(op, clientv3.OpPut(e.taskNodeKey(t.ID), string(workerBytes)))
 	}
+	return op, nil
+}
 
-	_, err = e.client.Txn(ctx).Then(op...).Commit()
+// SaveTaskScheduling rewrites a task's record to persist its scheduling
+// status. Only the task key is written, never the state key, so watchers of
+// state changes are not woken; nothing is written once the stored state no
+// longer matches t's.
+func (e *EtcdStore) SaveTaskScheduling(ctx context.Context, t *task.Task) error {
+	if t == nil {
+		return fmt.Errorf("task cannot be nil")
+	}
+
+	taskBytes, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	stateBytes, err := json.Marshal(t.State)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.client.Txn(ctx).If(
+		clientv3.Compare(clientv3.Value(e.taskStateKey(t.ID)), "=", string(stateBytes)),
+	).Then(
+		clientv3.OpPut(e.taskKey(t.ID), string(taskBytes)),
+	).Commit()
 	return err
 }
 
@@ -625,6 +798,92 @@ func (e *EtcdStore) GetNetworkTopology(ctx context.Context) (*topology.NetworkTo
 	return unmarshalNetworkTopology(resp.Kvs[0].Value)
 }
 
+// SaveTopologyOverrides persists administrator-declared topology facts. They
+// live under their own key so probe updates never overwrite them.
+func (e *EtcdStore) SaveTopologyOverrides(ctx context.Context, o *topology.Overrides) error {
+	if o == nil {
+		return fmt.Errorf("topology overrides cannot be nil")
+	}
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.client.Put(ctx, e.topologyOverridesKey(), string(data))
+	return err
+}
+
+// GetTopologyOverrides returns the administrator-declared topology facts, or
+// an empty set when none have been declared.
+func (e *EtcdStore) GetTopologyOverrides(ctx context.Context) (*topology.Overrides, error) {
+	resp, err := e.client.Get(ctx, e.topologyOverridesKey())
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count == 0 {
+		return &topology.Overrides{}, nil
+	}
+
+	var o topology.Overrides
+	if err := json.Unmarshal(resp.Kvs[0].Value, &o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// SaveBandwidthReservations replaces every ledger entry held by a task with
+// the given reservations in a single transaction.
+func (e *EtcdStore) SaveBandwidthReservations(ctx context.Context, taskID string, reservations []BandwidthReservation) error {
+	if taskID == "" {
+		return fmt.Errorf("bandwidth reservation requires a task ID")
+	}
+
+	prefix := e.taskReservationsPrefix(taskID)
+	ops := []clientv3.Op{clientv3.OpDelete(prefix, clientv3.WithPrefix())}
+	for _, r := range reservations {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, clientv3.OpPut(prefix+r.To, string(data)))
+	}
+
+	_, err := e.client.Txn(ctx).Then(ops...).Commit()
+	return err
+}
+
+// ListBandwidthReservations returns every entry in the bandwidth ledger.
+func (e *EtcdStore) ListBandwidthReservations(ctx context.Context) ([]BandwidthReservation, error) {
+	resp, err := e.client.Get(ctx, e.reservationsPrefix(), clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	reservations := make([]BandwidthReservation, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var r BandwidthReservation
+		if err := json.Unmarshal(kv.Value, &r); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, nil
+}
+
+// DeleteBandwidthReservations releases every ledger entry held by a task and
+// returns how many were removed.
+func (e *EtcdStore) DeleteBandwidthReservations(ctx context.Context, taskID string) (int64, error) {
+	if taskID == "" {
+		return 0, nil
+	}
+	resp, err := e.client.Delete(ctx, e.taskReservationsPrefix(taskID), clientv3.WithPrefix())
+	if err != nil {
+		return 0, err
+	}
+	return resp.Deleted, nil
+}
+
 func unmarshalNetworkTopology(data []byte) (*topology.NetworkTopology, error) {
 	var nt topology.NetworkTopology
 	if err := json.Unmarshal(data, &nt); err != nil {
@@ -19,6 +19,24 @@ type Worker struct {
 	ID        string    `json:"id"`
 	Address   string    `json:"address"`
 	Heartbeat time.Time `json:"heartbeat"`
+	Draining  bool      `json:"draining,omitempty"`
+	// Taints are set by operators with "okube nodes taint" and survive
+	// re-registration.
+	Taints []task.Taint `json:"taints,omitempty"`
+
+	// Capabilities reported by the worker at registration time.
+	Labels        map[string]string `json:"labels,omitempty"`
+	Arch          string            `json:"arch,omitempty"`
+	OS            string            `json:"os,omitempty"`
+	DockerVersion string            `json:"dockerVersion,omitempty"`
+	Capacity      WorkerCapacity    `json:"capacity"`
+}
+
+// WorkerCapacity describes the total resources of a worker host.
+type WorkerCapacity struct {
+	Cores     int    `json:"cores"`
+	MemoryKb  uint64 `json:"memoryKb"`
+	DiskBytes uint64 `json:"diskBytes"`
 }
 
 // TaskRecord includes a task along with its latest worker assignment.
@@ -34,16 +52,35 @@ type App struct {
 	Status       string            `json:"status"`        // deploying, running, stopping, stopped
 }
 
+// BandwidthReservation is one entry of the bandwidth ledger: the
+// bandwidth a placed task holds on the link to one of its dependencies. It
+// is keyed by task and dependency edge so it can be released with the task.
+type BandwidthReservation struct {
+	TaskID    string    `json:"taskId"`
+	AppID     string    `json:"appId"`
+	From      string    `json:"from"` // dependent service (the task's service)
+	To        string    `json:"to"`   // dependency service
+	FromNode  string    `json:"fromNode"`
+	ToNode    string    `json:"toNode"`
+	Mbps      float64   `json:"mbps"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 // Store defines the contract for persisting tasks and workers.
 type Store interface {
 	CreateTask(ctx context.Context, t *task.Task, workerID string) error
+	CreateTasks(ctx context.Context, records []TaskRecord) error
 	GetTask(ctx context.Context, id uuid.UUID) (*task.Task, string, error)
 	GetNodeOfTask(ctx context.Context, id uuid.UUID) (string, error)
 	UpdateTaskState(ctx context.Context, t *task.Task, workerID string) error
+	SaveTaskScheduling(ctx context.Context, t *task.Task) error
 	ListTasks(ctx context.Context) ([]TaskRecord, error)
 	RegisterWorker(ctx context.Context, worker Worker) error
 	ListWorkers(ctx context.Context) ([]Worker, error)
 	UpdateWorkerHeartbeat(ctx context.Context, workerID string, heartbeat time.Time) error
+	SetWorkerDraining(ctx context.Context, workerID string, draining bool) error
+	SetWorkerTaints(ctx context.Context, workerID string, taints []task.Taint) error
+	DeregisterWorker(ctx context.Context, workerID string) error
 
 	// AppGroup persistence
 	CreateAppGroup(ctx context.Context, ag *appgroup.AppGroup) error
@@ -53,6 +90,13 @@ type Store interface {
 	// Network topology persistence
 	SaveNetworkTopology(ctx context.Context, nt *topology.NetworkTopology) error
 	GetNetworkTopology(ctx context.Context) (*topology.NetworkTopology, error)
+	SaveTopologyOverrides(ctx context.Context, o *topology.Overrides) error
+	GetTopologyOverrides(ctx context.Context) (*topology.Overrides, error)
+
+	// Bandwidth reservation ledger
+	SaveBandwidthReservations(ctx context.Context, taskID string, reservations []BandwidthReservation) error
+	ListBandwidthReservations(ctx context.Context) ([]BandwidthReservation, error)
+	DeleteBandwidthReservations(ctx context.Context, taskID string) (int64, error)
 
 	// App persistence
 	CreateApp(ctx context.Context, app *App) error
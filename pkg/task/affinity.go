@@ -0,0 +1,299 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Operators for NodeSelectorRequirement.
+const (
+	SelectorOpIn           = "In"
+	SelectorOpNotIn        = "NotIn"
+	SelectorOpExists       = "Exists"
+	SelectorOpDoesNotExist = "DoesNotExist"
+	SelectorOpGt           = "Gt"
+	SelectorOpLt           = "Lt"
+)
+
+// NodeSelectorRequirement matches one node label against an operator and
+// values. Gt and Lt compare the label and the single value as integers.
+type NodeSelectorRequirement struct {
+	Key      string   `json:"key" yaml:"key"`
+	Operator string   `json:"operator" yaml:"operator"`
+	Values   []string `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// NodeSelectorTerm holds when every one of its requirements holds.
+type NodeSelectorTerm struct {
+	MatchExpressions []NodeSelectorRequirement `json:"matchExpressions" yaml:"matchExpressions"`
+}
+
+// PreferredSchedulingTerm is a node preference and its weight (1-100).
+type PreferredSchedulingTerm struct {
+	Weight     int              `json:"weight" yaml:"weight"`
+	Preference NodeSelectorTerm `json:"preference" yaml:"preference"`
+}
+
+// NodeAffinity constrains which nodes a task may run on. A node satisfies
+// Required when any one of its terms matches; Preferred terms only raise
+// the score of the nodes they match.
+type NodeAffinity struct {
+	Required  []NodeSelectorTerm        `json:"required,omitempty" yaml:"required,omitempty"`
+	Preferred []PreferredSchedulingTerm `json:"preferred,omitempty" yaml:"preferred,omitempty"`
+}
+
+// Matches reports whether labels satisfy the requirement.
+func (r NodeSelectorRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case SelectorOpIn:
+		return ok && containsString(r.Values, value)
+	case SelectorOpNotIn:
+		return !ok || !containsString(r.Values, value)
+	case SelectorOpExists:
+		return ok
+	case SelectorOpDoesNotExist:
+		return !ok
+	case SelectorOpGt, SelectorOpLt:
+		if !ok || len(r.Values) != 1 {
+			return false
+		}
+		have, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		want, err := strconv.ParseInt(r.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if r.Operator == SelectorOpGt {
+			return have > want
+		}
+		return have < want
+	default:
+		return false
+	}
+}
+
+// Validate checks the operator and the number of values it takes.
+func (r NodeSelectorRequirement) Validate() error {
+	if r.Key == "" {
+		return fmt.Errorf("requirement without a key")
+	}
+	switch r.Operator {
+	case SelectorOpIn, SelectorOpNotIn:
+		if len(r.Values) == 0 {
+			return fmt.Errorf("%s on %q needs at least one value", r.Operator, r.Key)
+		}
+	case SelectorOpExists, SelectorOpDoesNotExist:
+		if len(r.Values) != 0 {
+			return fmt.Errorf("%s on %q takes no values", r.Operator, r.Key)
+		}
+	case SelectorOpGt, SelectorOpLt:
+		if len(r.Values) != 1 {
+			return fmt.Errorf("%s on %q needs exactly one value", r.Operator, r.Key)
+		}
+		if _, err := strconv.ParseInt(r.Values[0], 10, 64); err != nil {
+			return fmt.Errorf("%s on %q needs an integer value", r.Operator, r.Key)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q on %q (expected In, NotIn, Exists, DoesNotExist, Gt or Lt)", r.Operator, r.Key)
+	}
+	return nil
+}
+
+// Matches reports whether labels satisfy every requirement of the term.
+// A term without requirements matches nothing.
+func (t NodeSelectorTerm) Matches(labels map[string]string) bool {
+	if len(t.MatchExpressions) == 0 {
+		return false
+	}
+	for _, r := range t.MatchExpressions {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequiredMatches reports whether labels satisfy the required terms.
+func (a *NodeAffinity) RequiredMatches(labels map[string]string) bool {
+	if a == nil || len(a.Required) == 0 {
+		return true
+	}
+	for _, term := range a.Required {
+		if term.Matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks every term of the affinity.
+func (a *NodeAffinity) Validate() error {
+	if a == nil {
+		return nil
+	}
+	for i, term := range a.Required {
+		if len(term.MatchExpressions) == 0 {
+			return fmt.Errorf("required term %d has no matchExpressions", i)
+		}
+		for _, r := range term.MatchExpressions {
+			if err := r.Validate(); err != nil {
+				return fmt.Errorf("required term %d: %w", i, err)
+			}
+		}
+	}
+	for i, pref := range a.Preferred {
+		if pref.Weight < 1 || pref.Weight > 100 {
+			return fmt.Errorf("preferred term %d: weight must be between 1 and 100", i)
+		}
+		if len(pref.Preference.MatchExpressions) == 0 {
+			return fmt.Errorf("preferred term %d has no matchExpressions", i)
+		}
+		for _, r := range pref.Preference.MatchExpressions {
+			if err := r.Validate(); err != nil {
+				return fmt.Errorf("preferred term %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Topology keys for ServiceAffinityTerm.
+const (
+	TopologyKeyNode   = "node"
+	TopologyKeyZone   = "zone"
+	TopologyKeyRegion = "region"
+)
+
+// ServiceAffinityTerm selects placed tasks and the topology domain they are
+// compared in. Services names services of the task's own app; MatchLabels
+// selects tasks of any app by task label. A task must satisfy every
+// selector that is set. TopologyKey is node (the default), zone or region.
+type ServiceAffinityTerm struct {
+	Services    []string          `json:"services,omitempty" yaml:"services,omitempty"`
+	MatchLabels map[string]string `json:"matchLabels,omitempty" yaml:"matchLabels,omitempty"`
+	TopologyKey string            `json:"topologyKey,omitempty" yaml:"topologyKey,omitempty"`
+}
+
+// WeightedServiceAffinityTerm is a preferred term and its weight (1-100).
+type WeightedServiceAffinityTerm struct {
+	Weight              int `json:"weight" yaml:"weight"`
+	ServiceAffinityTerm `yaml:",inline"`
+}
+
+// ServiceAffinity lists required and preferred terms. Under affinity a
+// term holds when a selected task is in the candidate node's domain; under
+// anti-affinity when none is.
+type ServiceAffinity struct {
+	Required  []ServiceAffinityTerm         `json:"required,omitempty" yaml:"required,omitempty"`
+	Preferred []WeightedServiceAffinityTerm `json:"preferred,omitempty" yaml:"preferred,omitempty"`
+}
+
+// Selects reports whether the term, written for a task of app appID,
+// selects other.
+func (term ServiceAffinityTerm) Selects(appID string, other *Task) bool {
+	if other == nil {
+		return false
+	}
+	if len(term.Services) > 0 {
+		service := other.ServiceID
+		if service == "" {
+			service = other.Name
+		}
+		if appID == "" || other.AppID != appID || !containsString(term.Services, service) {
+			return false
+		}
+	}
+	for k, v := range term.MatchLabels {
+		if have, ok := other.Labels[k]; !ok || have != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the selectors and topology key.
+func (term ServiceAffinityTerm) Validate() error {
+	if len(term.Services) == 0 && len(term.MatchLabels) == 0 {
+		return fmt.Errorf("term needs services or matchLabels")
+	}
+	switch term.TopologyKey {
+	case "", TopologyKeyNode, TopologyKeyZone, TopologyKeyRegion:
+	default:
+		return fmt.Errorf("unknown topologyKey %q (expected node, zone or region)", term.TopologyKey)
+	}
+	return nil
+}
+
+// Validate checks every term.
+func (a *ServiceAffinity) Validate() error {
+	if a == nil {
+		return nil
+	}
+	for i, term := range a.Required {
+		if err := term.Validate(); err != nil {
+			return fmt.Errorf("required term %d: %w", i, err)
+		}
+	}
+	for i, pref := range a.Preferred {
+		if pref.Weight < 1 || pref.Weight > 100 {
+			return fmt.Errorf("preferred term %d: weight must be between 1 and 100", i)
+		}
+		if err := pref.ServiceAffinityTerm.Validate(); err != nil {
+			return fmt.Errorf("preferred term %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Values of TopologySpreadConstraint.WhenUnsatisfiable.
+const (
+	SpreadReject = "reject"
+	SpreadPrefer = "prefer"
+)
+
+// TopologySpreadConstraint limits how unevenly tasks of one service may be
+// spread over the domains of TopologyKey (node, zone or region). The skew
+// of a placement is the number of the service's tasks in the chosen domain,
+// counting the new one, minus the number in the emptiest domain. Reject
+// (the default) refuses placements with a skew above MaxSkew; prefer only
+// scores them lower.
+type TopologySpreadConstraint struct {
+	MaxSkew           int    `json:"maxSkew" yaml:"maxSkew"`
+	TopologyKey       string `json:"topologyKey,omitempty" yaml:"topologyKey,omitempty"`
+	WhenUnsatisfiable string `json:"whenUnsatisfiable,omitempty" yaml:"whenUnsatisfiable,omitempty"`
+}
+
+// Prefer reports whether the constraint only affects scoring.
+func (c TopologySpreadConstraint) Prefer() bool {
+	return c.WhenUnsatisfiable == SpreadPrefer
+}
+
+// Validate checks the skew, topology key and unsatisfiable action.
+func (c TopologySpreadConstraint) Validate() error {
+	if c.MaxSkew < 1 {
+		return fmt.Errorf("maxSkew must be at least 1")
+	}
+	switch c.TopologyKey {
+	case "", TopologyKeyNode, TopologyKeyZone, TopologyKeyRegion:
+	default:
+		return fmt.Errorf("unknown topologyKey %q (expected node, zone or region)", c.TopologyKey)
+	}
+	switch c.WhenUnsatisfiable {
+	case "", SpreadReject, SpreadPrefer:
+	default:
+		return fmt.Errorf("unknown whenUnsatisfiable %q (expected reject or prefer)", c.WhenUnsatisfiable)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Effects of a Taint.
+const (
+	TaintNoSchedule       = "NoSchedule"
+	TaintPreferNoSchedule = "PreferNoSchedule"
+	TaintNoExecute        = "NoExecute"
+)
+
+// Operators for Toleration.
+const (
+	TolerationOpEqual  = "Equal"
+	TolerationOpExists = "Exists"
+)
+
+// Taint marks a worker so that only tasks tolerating it are placed there.
+// NoSchedule keeps new tasks off the worker, PreferNoSchedule only scores
+// it lower, and NoExecute also evicts running tasks that do not tolerate it.
+type Taint struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value,omitempty" yaml:"value,omitempty"`
+	Effect string `json:"effect" yaml:"effect"`
+}
+
+// String renders the taint as key=value:Effect, or key:Effect without a
+// value.
+func (t Taint) String() string {
+	if t.Value == "" {
+		return t.Key + ":" + t.Effect
+	}
+	return t.Key + "=" + t.Value + ":" + t.Effect
+}
+
+// Validate checks the key and effect.
+func (t Taint) Validate() error {
+	if t.Key == "" {
+		return fmt.Errorf("taint without a key")
+	}
+	switch t.Effect {
+	case TaintNoSchedule, TaintPreferNoSchedule, TaintNoExecute:
+	default:
+		return fmt.Errorf("unknown effect %q on taint %q (expected NoSchedule, PreferNoSchedule or NoExecute)", t.Effect, t.Key)
+	}
+	return nil
+}
+
+// ParseTaint parses key=value:Effect or key:Effect.
+func ParseTaint(s string) (Taint, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return Taint{}, fmt.Errorf("taint %q has no effect (expected key=value:Effect)", s)
+	}
+	t := Taint{Effect: s[i+1:]}
+	t.Key, t.Value, _ = strings.Cut(s[:i], "=")
+	if err := t.Validate(); err != nil {
+		return Taint{}, err
+	}
+	return t, nil
+}
+
+// Toleration lets a task be placed on, or keep running on, workers with a
+// matching taint. Equal (the default) matches the key and value, Exists any
+// value of the key; an empty key with Exists matches every taint. An empty
+// Effect matches all effects.
+type Toleration struct {
+	Key      string `json:"key,omitempty" yaml:"key,omitempty"`
+	Operator string `json:"operator,omitempty" yaml:"operator,omitempty"`
+	Value    string `json:"value,omitempty" yaml:"value,omitempty"`
+	Effect   string `json:"effect,omitempty" yaml:"effect,omitempty"`
+}
+
+// Tolerates reports whether the toleration matches taint.
+func (tol Toleration) Tolerates(taint Taint) bool {
+	if tol.Effect != "" && tol.Effect != taint.Effect {
+		return false
+	}
+	if tol.Key != "" && tol.Key != taint.Key {
+		return false
+	}
+	if tol.Operator == TolerationOpExists {
+		return true
+	}
+	return tol.Key != "" && tol.Value == taint.Value
+}
+
+// Validate checks the operator and effect.
+func (tol Toleration) Validate() error {
+	switch tol.Operator {
+	case "", TolerationOpEqual:
+		if tol.Key == "" {
+			return fmt.Errorf("toleration without a key needs operator Exists")
+		}
+	case TolerationOpExists:
+		if tol.Value != "" {
+			return fmt.Errorf("toleration of %q with operator Exists takes no value", tol.Key)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q on toleration %q (expected Equal or Exists)", tol.Operator, tol.Key)
+	}
+	switch tol.Effect {
+	case "", TaintNoSchedule, TaintPreferNoSchedule, TaintNoExecute:
+	default:
+		return fmt.Errorf("unknown effect %q on toleration %q", tol.Effect, tol.Key)
+	}
+	return nil
+}
+
+// ToleratesTaint reports whether any of the task's tolerations matches taint.
+func (t *Task) ToleratesTaint(taint Taint) bool {
+	for _, tol := range t.Tolerations {
+		if tol.Tolerates(taint) {
+			return true
+		}
+	}
+	return false
+}
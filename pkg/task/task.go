@@ -77,6 +77,40 @@ type Task struct {
 	Env          []string `json:"env,omitempty"`
 	Volumes      []string `json:"volumes,omitempty"`
 	Command      []string `json:"command,omitempty"`
+	// StopGracePeriod is how many seconds the container gets to exit after
+	// SIGTERM before it is killed. Zero uses the Docker daemon default.
+	StopGracePeriod int `json:"stopGracePeriod,omitempty"`
+	// SchedulerProfile names the manager's scheduler profile used to place
+	// the task; empty uses the default profile.
+	SchedulerProfile string `json:"schedulerProfile,omitempty"`
+	// NodeSelector lists labels a worker must carry, with these values, to
+	// run the task. NodeAffinity adds richer required and preferred rules.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	NodeAffinity *NodeAffinity     `json:"nodeAffinity,omitempty"`
+	// Labels describe the task to other tasks' affinity rules. Affinity and
+	// AntiAffinity place the task with, or away from, other tasks.
+	Labels       map[string]string `json:"labels,omitempty"`
+	Affinity     *ServiceAffinity  `json:"affinity,omitempty"`
+	AntiAffinity *ServiceAffinity  `json:"antiAffinity,omitempty"`
+	// SpreadConstraints limit how unevenly tasks of the same service are
+	// spread over nodes, zones or regions.
+	SpreadConstraints []TopologySpreadConstraint `json:"spreadConstraints,omitempty"`
+	// Tolerations let the task run on workers with matching taints.
+	Tolerations []Toleration `json:"tolerations,omitempty"`
+	// Scheduling records the manager's attempts to place the task.
+	Scheduling *SchedulingStatus `json:"scheduling,omitempty"`
+}
+
+// SchedulingStatus is the outcome of the scheduler's attempts to place a
+// task. Attempts counts every placement decision, successful or not;
+// LastReason explains the latest failure and is cleared once a node is
+// chosen, at which point Node and Score describe the choice.
+type SchedulingStatus struct {
+	Attempts    int       `json:"attempts"`
+	LastAttempt time.Time `json:"lastAttempt"`
+	LastReason  string    `json:"lastReason,omitempty"`
+	Node        string    `json:"node,omitempty"`
+	Score       *float64  `json:"score,omitempty"`
 }
 
 type TaskEvent struct {
@@ -100,6 +134,7 @@ type Config struct {
 	Env           []string
 	RestartPolicy string
 	Volumes       []string
+	StopTimeout   int
 }
 
 func NewConfig(t *Task) *Config {
@@ -113,6 +148,7 @@ func NewConfig(t *Task) *Config {
 		Env:           t.Env,
 		Volumes:       t.Volumes,
 		Cmd:           t.Command,
+		StopTimeout:   t.StopGracePeriod,
 	}
 }
 
@@ -224,7 +260,12 @@ func (d *Docker) Run() DockerResult {
 func (d *Docker) Stop(id string) DockerResult {
 	log.Printf("Attempting to stop container %v", id)
 	ctx := context.Background()
-	err := d.Client.ContainerStop(ctx, id, container.StopOptions{})
+	opts := container.StopOptions{}
+	if d.Config.StopTimeout > 0 {
+		timeout := d.Config.StopTimeout
+		opts.Timeout = &timeout
+	}
+	err := d.Client.ContainerStop(ctx, id, opts)
 	if err != nil {
 		log.Printf("Error stopping container %s : %v\n", id, err)
 		return DockerResult{Error: err}
@@ -0,0 +1,239 @@
+package topology
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLatencyWindow is the number of probe outcomes kept per link.
+	DefaultLatencyWindow = 20
+	// DefaultEWMAAlpha is the weight given to the newest sample when
+	// updating the exponentially weighted moving average.
+	DefaultEWMAAlpha = 0.3
+)
+
+// LatencyStatistic selects which summary of a link's samples a consumer
+// wants to treat as "the" latency of that link.
+type LatencyStatistic string
+
+const (
+	LatencyLatest LatencyStatistic = "latest"
+	LatencyEWMA   LatencyStatistic = "ewma"
+	LatencyP50    LatencyStatistic = "p50"
+	LatencyP95    LatencyStatistic = "p95"
+)
+
+// ParseLatencyStatistic maps a flag or config value to a LatencyStatistic,
+// falling back to def when raw is empty or unknown.
+func ParseLatencyStatistic(raw string, def LatencyStatistic) LatencyStatistic {
+	switch LatencyStatistic(strings.ToLower(strings.TrimSpace(raw))) {
+	case LatencyLatest:
+		return LatencyLatest
+	case LatencyEWMA:
+		return LatencyEWMA
+	case LatencyP50, "median":
+		return LatencyP50
+	case LatencyP95:
+		return LatencyP95
+	default:
+		return def
+	}
+}
+
+// LatencySample is a single probe outcome. Lost samples carry no RTT.
+type LatencySample struct {
+	RttMs float64 `json:"rttMs"`
+	Lost  bool    `json:"lost,omitempty"`
+}
+
+// LinkLatency is the rolling sample window for one directed link together
+// with the statistics derived from it. All latencies are in milliseconds.
+type LinkLatency struct {
+	Window    []LatencySample `json:"window"`
+	Latest    float64         `json:"latest"`
+	EWMA      float64         `json:"ewma"`
+	P50       float64         `json:"p50"`
+	P95       float64         `json:"p95"`
+	Jitter    float64         `json:"jitter"`
+	LossRate  float64         `json:"lossRate"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	// MeasuredAt is the time of the last successful sample; lost probes
+	// only move UpdatedAt.
+	MeasuredAt time.Time `json:"measuredAt"`
+	// ConsecutiveFailures counts lost probes since the last success.
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+}
+
+// Value returns the requested statistic and whether the link has any
+// successful samples to derive it from.
+func (l *LinkLatency) Value(stat LatencyStatistic) (float64, bool) {
+	if l == nil || l.received() == 0 {
+		return 0, false
+	}
+	switch stat {
+	case LatencyLatest:
+		return l.Latest, true
+	case LatencyP50:
+		return l.P50, true
+	case LatencyP95:
+		return l.P95, true
+	default:
+		return l.EWMA, true
+	}
+}
+
+func (l *LinkLatency) received() int {
+	n := 0
+	for _, s := range l.Window {
+		if !s.Lost {
+			n++
+		}
+	}
+	return n
+}
+
+// record appends a probe outcome, trims the window to size and recomputes
+// the summary statistics.
+func (l *LinkLatency) record(sample LatencySample, size int, alpha float64, at time.Time) {
+	if size <= 0 {
+		size = DefaultLatencyWindow
+	}
+	if alpha <= 0 || alpha > 1 {
+		alpha = DefaultEWMAAlpha
+	}
+
+	firstSuccess := l.received() == 0
+	l.Window = append(l.Window, sample)
+	if len(l.Window) > size {
+		l.Window = append([]LatencySample(nil), l.Window[len(l.Window)-size:]...)
+	}
+	l.UpdatedAt = at
+
+	if sample.Lost {
+		l.ConsecutiveFailures++
+	} else {
+		l.ConsecutiveFailures = 0
+		l.MeasuredAt = at
+		l.Latest = sample.RttMs
+		if firstSuccess {
+			l.EWMA = sample.RttMs
+		} else {
+			l.EWMA = alpha*sample.RttMs + (1-alpha)*l.EWMA
+		}
+	}
+
+	rtts := make([]float64, 0, len(l.Window))
+	lost := 0
+	for _, s := range l.Window {
+		if s.Lost {
+			lost++
+			continue
+		}
+		rtts = append(rtts, s.RttMs)
+	}
+	l.LossRate = float64(lost) / float64(len(l.Window))
+
+	// Jitter is the mean absolute difference between consecutive RTTs, in
+	// the spirit of RFC 3550's interarrival jitter.
+	l.Jitter = 0
+	if len(rtts) > 1 {
+		sum := 0.0
+		for i := 1; i < len(rtts); i++ {
+			sum += math.Abs(rtts[i] - rtts[i-1])
+		}
+		l.Jitter = sum / float64(len(rtts)-1)
+	}
+
+	sort.Float64s(rtts)
+	l.P50 = percentile(rtts, 0.50)
+	l.P95 = percentile(rtts, 0.95)
+}
+
+// percentile returns the nearest-rank percentile of an ascending slice.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// RecordLatencySample adds a successful probe to the link's window and
+// refreshes NodeLatencies[nodeA][nodeB] with the smoothed (EWMA) value so
+// callers of GetLatency are not thrown off by a single outlier.
+func (nt *NetworkTopology) RecordLatencySample(nodeA, nodeB string, rttMs float64, window int, alpha float64) {
+	if nt == nil {
+		return
+	}
+	link := nt.ensureLinkLatency(nodeA, nodeB)
+	link.record(LatencySample{RttMs: rttMs}, window, alpha, time.Now().UTC())
+	nt.setLatency(nodeA, nodeB, link.EWMA)
+}
+
+// RecordLatencyLoss marks a failed probe on the link. Existing latency
+// values are left untouched; only the loss rate changes.
+func (nt *NetworkTopology) RecordLatencyLoss(nodeA, nodeB string, window int, alpha float64) {
+	if nt == nil {
+		return
+	}
+	link := nt.ensureLinkLatency(nodeA, nodeB)
+	link.record(LatencySample{Lost: true}, window, alpha, time.Now().UTC())
+}
+
+// GetLinkLatency returns the sample window and statistics for a link.
+func (nt *NetworkTopology) GetLinkLatency(nodeA, nodeB string) (*LinkLatency, bool) {
+	if nt == nil || nt.LatencyStats == nil {
+		return nil, false
+	}
+	peers, ok := nt.LatencyStats[nodeA]
+	if !ok {
+		return nil, false
+	}
+	link, ok := peers[nodeB]
+	return link, ok && link != nil
+}
+
+// GetLatencyStatistic returns the chosen statistic for a link. Links that
+// predate sample windows fall back to the plain NodeLatencies value.
+func (nt *NetworkTopology) GetLatencyStatistic(nodeA, nodeB string, stat LatencyStatistic) (float64, bool) {
+	if link, ok := nt.GetLinkLatency(nodeA, nodeB); ok {
+		if v, ok := link.Value(stat); ok {
+			return v, true
+		}
+	}
+	return nt.GetLatency(nodeA, nodeB)
+}
+
+func (nt *NetworkTopology) ensureLinkLatency(nodeA, nodeB string) *LinkLatency {
+	if nt.LatencyStats == nil {
+		nt.LatencyStats = make(map[string]map[string]*LinkLatency)
+	}
+	if _, ok := nt.LatencyStats[nodeA]; !ok {
+		nt.LatencyStats[nodeA] = make(map[string]*LinkLatency)
+	}
+	link, ok := nt.LatencyStats[nodeA][nodeB]
+	if !ok || link == nil {
+		link = &LinkLatency{}
+		nt.LatencyStats[nodeA][nodeB] = link
+	}
+	return link
+}
+
+func (nt *NetworkTopology) setLatency(nodeA, nodeB string, latency float64) {
+	if nt.NodeLatencies == nil {
+		nt.NodeLatencies = make(map[string]map[string]float64)
+	}
+	if _, ok := nt.NodeLatencies[nodeA]; !ok {
+		nt.NodeLatencies[nodeA] = make(map[string]float64)
+	}
+	nt.NodeLatencies[nodeA][nodeB] = latency
+}
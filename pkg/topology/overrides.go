@@ -0,0 +1,185 @@
+package topology
+
+// Overrides holds administrator-declared topology facts. They are stored
+// apart from the probed NetworkTopology so probe rounds never overwrite them,
+// and are merged over the probed data with ApplyOverrides.
+type Overrides struct {
+	Zones   map[string]string                  `json:"zones,omitempty"`
+	Regions map[string]string                  `json:"regions,omitempty"`
+	Links   map[string]map[string]LinkOverride `json:"links,omitempty"`
+}
+
+// LinkOverride pins properties of the directed link from one node to
+// another. Nil fields leave the probed value in place.
+type LinkOverride struct {
+	// LatencyMs replaces the measured latency (network cost) of the link.
+	LatencyMs *float64 `json:"latencyMs,omitempty"`
+	// BandwidthCapMbps limits the link's capacity to at most this value.
+	BandwidthCapMbps *float64 `json:"bandwidthCapMbps,omitempty"`
+	// Forbidden links may never carry traffic between dependent services.
+	Forbidden bool `json:"forbidden,omitempty"`
+}
+
+// IsEmpty reports whether the override no longer changes anything.
+func (o LinkOverride) IsEmpty() bool {
+	return o.LatencyMs == nil && o.BandwidthCapMbps == nil && !o.Forbidden
+}
+
+// GetLink returns the override for a link.
+func (o *Overrides) GetLink(nodeA, nodeB string) (LinkOverride, bool) {
+	if o == nil || o.Links == nil {
+		return LinkOverride{}, false
+	}
+	peers, ok := o.Links[nodeA]
+	if !ok {
+		return LinkOverride{}, false
+	}
+	link, ok := peers[nodeB]
+	return link, ok
+}
+
+// SetLink stores an override for a link, removing it when it is empty.
+func (o *Overrides) SetLink(nodeA, nodeB string, link LinkOverride) {
+	if link.IsEmpty() {
+		o.DeleteLink(nodeA, nodeB)
+		return
+	}
+	if o.Links == nil {
+		o.Links = make(map[string]map[string]LinkOverride)
+	}
+	if _, ok := o.Links[nodeA]; !ok {
+		o.Links[nodeA] = make(map[string]LinkOverride)
+	}
+	o.Links[nodeA][nodeB] = link
+}
+
+// DeleteLink removes any override for a link.
+func (o *Overrides) DeleteLink(nodeA, nodeB string) {
+	if o == nil || o.Links == nil {
+		return
+	}
+	delete(o.Links[nodeA], nodeB)
+	if len(o.Links[nodeA]) == 0 {
+		delete(o.Links, nodeA)
+	}
+}
+
+// SetNodePlacement sets a node's zone and region. Empty values clear them.
+func (o *Overrides) SetNodePlacement(nodeID, zone, region string) {
+	if o.Zones == nil {
+		o.Zones = make(map[string]string)
+	}
+	if o.Regions == nil {
+		o.Regions = make(map[string]string)
+	}
+	if zone == "" {
+		delete(o.Zones, nodeID)
+	} else {
+		o.Zones[nodeID] = zone
+	}
+	if region == "" {
+		delete(o.Regions, nodeID)
+	} else {
+		o.Regions[nodeID] = region
+	}
+}
+
+// ApplyOverrides returns a copy of the topology with the overrides merged
+// over the probed data:
+//   - declared zones and regions replace any probed mapping;
+//   - pinned latencies replace the measured value and its statistics;
+//   - bandwidth caps lower capacity and available bandwidth, keeping
+//     bandwidth already reserved on the link accounted for;
+//   - forbidden links are recorded in ForbiddenLinks.
+func (nt *NetworkTopology) ApplyOverrides(o *Overrides) *NetworkTopology {
+	out := nt.Clone()
+	if out == nil {
+		out = &NetworkTopology{}
+	}
+	if o == nil {
+		return out
+	}
+
+	if len(o.Zones) > 0 && out.ZoneMapping == nil {
+		out.ZoneMapping = make(map[string]string)
+	}
+	for id, zone := range o.Zones {
+		out.ZoneMapping[id] = zone
+	}
+	if len(o.Regions) > 0 && out.RegionMapping == nil {
+		out.RegionMapping = make(map[string]string)
+	}
+	for id, region := range o.Regions {
+		out.RegionMapping[id] = region
+	}
+
+	for from, peers := range o.Links {
+		for to, link := range peers {
+			if link.LatencyMs != nil {
+				if out.LatencyStats != nil {
+					delete(out.LatencyStats[from], to)
+				}
+				out.setLatency(from, to, *link.LatencyMs)
+			}
+			if link.BandwidthCapMbps != nil {
+				out.capBandwidth(from, to, *link.BandwidthCapMbps)
+			}
+			if link.Forbidden {
+				if out.ForbiddenLinks == nil {
+					out.ForbiddenLinks = make(map[string]map[string]bool)
+				}
+				if _, ok := out.ForbiddenLinks[from]; !ok {
+					out.ForbiddenLinks[from] = make(map[string]bool)
+				}
+				out.ForbiddenLinks[from][to] = true
+			}
+		}
+	}
+
+	return out
+}
+
+func (nt *NetworkTopology) capBandwidth(nodeA, nodeB string, limit float64) {
+	if limit < 0 {
+		limit = 0
+	}
+
+	capacity, hasCapacity := nt.GetBandwidth(nodeA, nodeB)
+	available, hasAvailable := nt.GetAvailableBandwidth(nodeA, nodeB)
+
+	effective := limit
+	if hasCapacity && capacity < limit {
+		effective = capacity
+	}
+
+	if nt.NodeBandwidth == nil {
+		nt.NodeBandwidth = make(map[string]map[string]float64)
+	}
+	if _, ok := nt.NodeBandwidth[nodeA]; !ok {
+		nt.NodeBandwidth[nodeA] = make(map[string]float64)
+	}
+	nt.NodeBandwidth[nodeA][nodeB] = effective
+
+	if !hasAvailable {
+		return
+	}
+	next := available
+	if hasCapacity {
+		next = effective - (capacity - available)
+	}
+	if next > effective {
+		next = effective
+	}
+	if next < 0 {
+		next = 0
+	}
+	nt.AvailableBandwidth[nodeA][nodeB] = next
+}
+
+// IsLinkForbidden reports whether an administrator has forbidden the link.
+func (nt *NetworkTopology) IsLinkForbidden(nodeA, nodeB string) bool {
+	if nt == nil || nt.ForbiddenLinks == nil {
+		return false
+	}
+	return nt.ForbiddenLinks[nodeA][nodeB]
+}
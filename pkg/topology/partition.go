@@ -0,0 +1,133 @@
+package topology
+
+import "sort"
+
+// DefaultFailureThreshold is the number of consecutive lost probes after
+// which a link is considered down.
+const DefaultFailureThreshold = 3
+
+// IsLinkDown reports whether the last threshold probes on the link were all
+// lost.
+func (nt *NetworkTopology) IsLinkDown(nodeA, nodeB string, threshold int) bool {
+	if threshold <= 0 {
+		threshold = DefaultFailureThreshold
+	}
+	link, ok := nt.GetLinkLatency(nodeA, nodeB)
+	return ok && link.ConsecutiveFailures >= threshold
+}
+
+// isLinkUp reports whether the link has been measured successfully and is
+// not down.
+func (nt *NetworkTopology) isLinkUp(nodeA, nodeB string, threshold int) bool {
+	link, ok := nt.GetLinkLatency(nodeA, nodeB)
+	return ok && !link.MeasuredAt.IsZero() && link.ConsecutiveFailures < threshold
+}
+
+// ComputePartitions groups nodes into connected components, treating two
+// nodes as connected when a probe in either direction currently succeeds.
+// Nodes with no probe history towards any other listed node are left out
+// because nothing is known about them yet. Components are ordered largest
+// first, ties broken by their smallest node ID, and each is sorted.
+func (nt *NetworkTopology) ComputePartitions(nodeIDs []string, threshold int) [][]string {
+	if threshold <= 0 {
+		threshold = DefaultFailureThreshold
+	}
+
+	parent := make(map[string]string, len(nodeIDs))
+	var find func(string) string
+	find = func(id string) string {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+
+	observed := make(map[string]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		parent[id] = id
+	}
+	for _, a := range nodeIDs {
+		for _, b := range nodeIDs {
+			if a == b {
+				continue
+			}
+			if _, ok := nt.GetLinkLatency(a, b); ok {
+				observed[a], observed[b] = true, true
+			}
+			if nt.isLinkUp(a, b, threshold) {
+				if ra, rb := find(a), find(b); ra != rb {
+					parent[ra] = rb
+				}
+			}
+		}
+	}
+
+	groups := make(map[string][]string)
+	for _, id := range nodeIDs {
+		if !observed[id] {
+			continue
+		}
+		root := find(id)
+		groups[root] = append(groups[root], id)
+	}
+
+	components := make([][]string, 0, len(groups))
+	for _, members := range groups {
+		sort.Strings(members)
+		components = append(components, members)
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if len(components[i]) != len(components[j]) {
+			return len(components[i]) > len(components[j])
+		}
+		return components[i][0] < components[j][0]
+	})
+	return components
+}
+
+// MarkPartitions recomputes the components of nodeIDs and records them in
+// Partitions. It returns the components.
+func (nt *NetworkTopology) MarkPartitions(nodeIDs []string, threshold int) [][]string {
+	components := nt.ComputePartitions(nodeIDs, threshold)
+	nt.Partitions = make(map[string]int)
+	for i, members := range components {
+		for _, id := range members {
+			nt.Partitions[id] = i
+		}
+	}
+	return components
+}
+
+// GetPartition returns the component a node was last assigned to.
+func (nt *NetworkTopology) GetPartition(nodeID string) (int, bool) {
+	if nt == nil || nt.Partitions == nil {
+		return 0, false
+	}
+	p, ok := nt.Partitions[nodeID]
+	return p, ok
+}
+
+// SamePartition reports whether two nodes can reach each other. Nodes whose
+// partition is unknown are given the benefit of the doubt.
+func (nt *NetworkTopology) SamePartition(nodeA, nodeB string) bool {
+	pa, okA := nt.GetPartition(nodeA)
+	pb, okB := nt.GetPartition(nodeB)
+	if !okA || !okB {
+		return true
+	}
+	return pa == pb
+}
+
+// IsPartitioned reports whether the last computation found more than one
+// component.
+func (nt *NetworkTopology) IsPartitioned() bool {
+	if nt == nil {
+		return false
+	}
+	for _, p := range nt.Partitions {
+		if p > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package topology
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// GetLatencyMeasuredAt returns when the link's latency was last successfully
+// measured.
+func (nt *NetworkTopology) GetLatencyMeasuredAt(nodeA, nodeB string) (time.Time, bool) {
+	link, ok := nt.GetLinkLatency(nodeA, nodeB)
+	if !ok || link.MeasuredAt.IsZero() {
+		return time.Time{}, false
+	}
+	return link.MeasuredAt, true
+}
+
+// Clone returns a deep copy of the topology.
+func (nt *NetworkTopology) Clone() *NetworkTopology {
+	if nt == nil {
+		return nil
+	}
+	raw, err := json.Marshal(nt)
+	if err != nil {
+		return nil
+	}
+	out := &NetworkTopology{}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// WithoutStale returns a copy of the topology in which links measured longer
+// than latencyTTL (latency) or bandwidthTTL (bandwidth) before now are
+// removed, so consumers treat them as unknown. A non-positive TTL disables
+// expiry for that metric. Entries that carry no measurement timestamp were
+// not produced by the probers (e.g. seeded by hand) and are kept.
+func (nt *NetworkTopology) WithoutStale(latencyTTL, bandwidthTTL time.Duration, now time.Time) *NetworkTopology {
+	out := nt.Clone()
+	if out == nil {
+		return nil
+	}
+
+	if latencyTTL > 0 {
+		for from, peers := range out.NodeLatencies {
+			for to := range peers {
+				link, ok := out.GetLinkLatency(from, to)
+				if !ok || link.MeasuredAt.IsZero() {
+					continue
+				}
+				if now.Sub(link.MeasuredAt) > latencyTTL {
+					delete(peers, to)
+					delete(out.LatencyStats[from], to)
+				}
+			}
+		}
+	}
+
+	if bandwidthTTL > 0 {
+		for from, peers := range out.BandwidthMeasuredAt {
+			for to, at := range peers {
+				if now.Sub(at) <= bandwidthTTL {
+					continue
+				}
+				delete(peers, to)
+				if row, ok := out.NodeBandwidth[from]; ok {
+					delete(row, to)
+				}
+				if row, ok := out.AvailableBandwidth[from]; ok {
+					delete(row, to)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// NodeIDs returns every node that appears anywhere in the topology.
+func (nt *NetworkTopology) NodeIDs() map[string]bool {
+	ids := make(map[string]bool)
+	if nt == nil {
+		return ids
+	}
+	addMatrix := func(m map[string]map[string]float64) {
+		for from, peers := range m {
+			ids[from] = true
+			for to := range peers {
+				ids[to] = true
+			}
+		}
+	}
+	addMatrix(nt.NodeLatencies)
+	addMatrix(nt.NodeBandwidth)
+	addMatrix(nt.AvailableBandwidth)
+	for from, peers := range nt.LatencyStats {
+		ids[from] = true
+		for to := range peers {
+			ids[to] = true
+		}
+	}
+	for from, peers := range nt.BandwidthMeasuredAt {
+		ids[from] = true
+		for to := range peers {
+			ids[to] = true
+		}
+	}
+	for id := range nt.Coordinates {
+		ids[id] = true
+	}
+	for id := range nt.ZoneMapping {
+		ids[id] = true
+	}
+	for id := range nt.RegionMapping {
+		ids[id] = true
+	}
+	return ids
+}
+
+// RemoveNode deletes every row, column and mapping that refers to nodeID.
+func (nt *NetworkTopology) RemoveNode(nodeID string) {
+	if nt == nil {
+		return
+	}
+	pruneMatrix := func(m map[string]map[string]float64) {
+		delete(m, nodeID)
+		for _, peers := range m {
+			delete(peers, nodeID)
+		}
+	}
+	pruneMatrix(nt.NodeLatencies)
+	pruneMatrix(nt.NodeBandwidth)
+	pruneMatrix(nt.AvailableBandwidth)
+
+	delete(nt.LatencyStats, nodeID)
+	for _, peers := range nt.LatencyStats {
+		delete(peers, nodeID)
+	}
+	delete(nt.BandwidthMeasuredAt, nodeID)
+	for _, peers := range nt.BandwidthMeasuredAt {
+		delete(peers, nodeID)
+	}
+
+	delete(nt.Coordinates, nodeID)
+	delete(nt.ZoneMapping, nodeID)
+	delete(nt.RegionMapping, nodeID)
+	delete(nt.Partitions, nodeID)
+}
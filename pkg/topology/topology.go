@@ -1,5 +1,7 @@
 package topology
 
+import "time"
+
 // NetworkTopology models observed network properties across cluster nodes.
 type NetworkTopology struct {
 	Version            int64                         `json:"version"`
@@ -8,6 +10,21 @@ type NetworkTopology struct {
 	AvailableBandwidth map[string]map[string]float64 `json:"availableBandwidth"`
 	ZoneMapping        map[string]string             `json:"zoneMapping"`
 	RegionMapping      map[string]string             `json:"regionMapping"`
+	// BandwidthMeasuredAt records when each NodeBandwidth entry was last
+	// measured by a throughput probe.
+	BandwidthMeasuredAt map[string]map[string]time.Time `json:"bandwidthMeasuredAt,omitempty"`
+	// LatencyStats keeps a rolling sample window per link with derived
+	// EWMA, percentiles, jitter and loss. NodeLatencies mirrors the EWMA.
+	LatencyStats map[string]map[string]*LinkLatency `json:"latencyStats,omitempty"`
+	// Coordinates holds each node's Vivaldi coordinate, used to estimate
+	// latency for pairs that have not been probed.
+	Coordinates map[string]*Coordinate `json:"coordinates,omitempty"`
+	// ForbiddenLinks is only populated by ApplyOverrides and is never
+	// written by the probers.
+	ForbiddenLinks map[string]map[string]bool `json:"forbiddenLinks,omitempty"`
+	// Partitions maps each live node to the connected component of
+	// reachable workers it belongs to. Component 0 is the largest.
+	Partitions map[string]int `json:"partitions,omitempty"`
 }
 
 // GetLatency returns the latency between two nodes and whether a value exists.
@@ -49,6 +66,73 @@ func (nt *NetworkTopology) GetAvailableBandwidth(nodeA, nodeB string) (float64,
 	return bandwidth, ok
 }
 
+// SetBandwidth records a measured link capacity in Mbps. Any existing
+// available-bandwidth entry is shifted by the change in capacity so that
+// outstanding reservations are preserved, and clamped to [0, capacity].
+func (nt *NetworkTopology) SetBandwidth(nodeA, nodeB string, capacity float64, measuredAt time.Time) {
+	if nt == nil || capacity < 0 {
+		return
+	}
+
+	previous, hadPrevious := nt.GetBandwidth(nodeA, nodeB)
+
+	if nt.NodeBandwidth == nil {
+		nt.NodeBandwidth = make(map[string]map[string]float64)
+	}
+	if _, ok := nt.NodeBandwidth[nodeA]; !ok {
+		nt.NodeBandwidth[nodeA] = make(map[string]float64)
+	}
+	nt.NodeBandwidth[nodeA][nodeB] = capacity
+
+	if nt.BandwidthMeasuredAt == nil {
+		nt.BandwidthMeasuredAt = make(map[string]map[string]time.Time)
+	}
+	if _, ok := nt.BandwidthMeasuredAt[nodeA]; !ok {
+		nt.BandwidthMeasuredAt[nodeA] = make(map[string]time.Time)
+	}
+	nt.BandwidthMeasuredAt[nodeA][nodeB] = measuredAt
+
+	available, ok := nt.GetAvailableBandwidth(nodeA, nodeB)
+	if !ok {
+		return
+	}
+	if hadPrevious {
+		available += capacity - previous
+	}
+	if available < 0 {
+		available = 0
+	}
+	if available > capacity {
+		available = capacity
+	}
+	nt.AvailableBandwidth[nodeA][nodeB] = available
+}
+
+// SetAvailableBandwidth overwrites the remaining bandwidth on a link.
+func (nt *NetworkTopology) SetAvailableBandwidth(nodeA, nodeB string, available float64) {
+	if nt == nil {
+		return
+	}
+	if available < 0 {
+		available = 0
+	}
+	nt.ensureAvailableMap(nodeA)
+	nt.AvailableBandwidth[nodeA][nodeB] = available
+}
+
+// GetBandwidthMeasuredAt returns when the link's capacity was last measured.
+func (nt *NetworkTopology) GetBandwidthMeasuredAt(nodeA, nodeB string) (time.Time, bool) {
+	if nt == nil || nt.BandwidthMeasuredAt == nil {
+		return time.Time{}, false
+	}
+	peers, ok := nt.BandwidthMeasuredAt[nodeA]
+	if !ok {
+		return time.Time{}, false
+	}
+	at, ok := peers[nodeB]
+	return at, ok
+}
+
 // ReserveBandwidth decrements available bandwidth on a link. It returns false
 // when the link has no known capacity or insufficient remaining bandwidth.
 func (nt *NetworkTopology) ReserveBandwidth(nodeA, nodeB string, amount float64) bool {
@@ -88,6 +172,27 @@ func (nt *NetworkTopology) ReleaseBandwidth(nodeA, nodeB string, amount float64)
 	return true
 }
 
+// ApplyReservations recomputes AvailableBandwidth from the link capacities
+// and the total bandwidth reserved on each link (from -> to -> Mbps), so
+// available bandwidth is always derived from the reservation ledger rather
+// than accumulated in the topology. Links without a known capacity are left
+// unchanged. It mutates the topology and is meant for scheduling copies.
+func (nt *NetworkTopology) ApplyReservations(reserved map[string]map[string]float64) {
+	if nt == nil {
+		return
+	}
+	for from, peers := range nt.NodeBandwidth {
+		for to, capacity := range peers {
+			available := capacity - reserved[from][to]
+			if available < 0 {
+				available = 0
+			}
+			nt.ensureAvailableMap(from)
+			nt.AvailableBandwidth[from][to] = available
+		}
+	}
+}
+
 func (nt *NetworkTopology) ensureAvailableMap(nodeA string) {
 	if nt.AvailableBandwidth == nil {
 		nt.AvailableBandwidth = make(map[string]map[string]float64)
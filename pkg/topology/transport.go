@@ -0,0 +1,197 @@
+package topology
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ProbeTransport selects how a single round trip to a peer is measured.
+type ProbeTransport string
+
+const (
+	// ProbeTransportHTTP times a GET of the peer's /probe/ping endpoint over
+	// a kept-alive connection.
+	ProbeTransportHTTP ProbeTransport = "http"
+	// ProbeTransportTCP times the TCP three-way handshake to the peer's API
+	// port, which costs exactly one round trip and no application work.
+	ProbeTransportTCP ProbeTransport = "tcp"
+	// ProbeTransportUDP times a datagram echoed by the peer's UDP echo
+	// responder, which listens on the same port number as its API.
+	ProbeTransportUDP ProbeTransport = "udp"
+)
+
+// UDPEchoPacketSize is the size of a UDP echo probe datagram.
+const UDPEchoPacketSize = 16
+
+const defaultProbeTimeout = 3 * time.Second
+
+// ParseProbeTransport maps a flag or query value to a ProbeTransport,
+// falling back to def when raw is empty or unknown.
+func ParseProbeTransport(raw string, def ProbeTransport) ProbeTransport {
+	switch ProbeTransport(strings.ToLower(strings.TrimSpace(raw))) {
+	case ProbeTransportHTTP:
+		return ProbeTransportHTTP
+	case ProbeTransportTCP:
+		return ProbeTransportTCP
+	case ProbeTransportUDP:
+		return ProbeTransportUDP
+	default:
+		return def
+	}
+}
+
+// RTTMeter measures round trips to one peer address. Implementations may
+// keep connections open between samples; Close releases them.
+type RTTMeter interface {
+	Measure(ctx context.Context) (time.Duration, error)
+	Close() error
+}
+
+// NewRTTMeter returns a meter for address (host:port) using transport.
+func NewRTTMeter(transport ProbeTransport, address string) RTTMeter {
+	switch transport {
+	case ProbeTransportTCP:
+		return &tcpConnectMeter{address: address}
+	case ProbeTransportUDP:
+		return &udpEchoMeter{address: address}
+	default:
+		return &httpPingMeter{
+			url:    fmt.Sprintf("http://%s/probe/ping", address),
+			client: &http.Client{Timeout: defaultProbeTimeout, Transport: &http.Transport{}},
+		}
+	}
+}
+
+type httpPingMeter struct {
+	url    string
+	client *http.Client
+}
+
+func (m *httpPingMeter) Measure(ctx context.Context) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return 0, fmt.Errorf("ping %s failed with status %d", m.url, resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+func (m *httpPingMeter) Close() error {
+	m.client.CloseIdleConnections()
+	return nil
+}
+
+type tcpConnectMeter struct {
+	address string
+}
+
+func (m *tcpConnectMeter) Measure(ctx context.Context) (time.Duration, error) {
+	dialer := net.Dialer{Timeout: defaultProbeTimeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", m.address)
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start)
+	conn.Close()
+	return elapsed, nil
+}
+
+func (m *tcpConnectMeter) Close() error { return nil }
+
+type udpEchoMeter struct {
+	address string
+	conn    net.Conn
+}
+
+func (m *udpEchoMeter) Measure(ctx context.Context) (time.Duration, error) {
+	if m.conn == nil {
+		dialer := net.Dialer{Timeout: defaultProbeTimeout}
+		conn, err := dialer.DialContext(ctx, "udp", m.address)
+		if err != nil {
+			return 0, err
+		}
+		m.conn = conn
+	}
+
+	nonce := make([]byte, UDPEchoPacketSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+
+	deadline := time.Now().Add(defaultProbeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := m.conn.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if _, err := m.conn.Write(nonce); err != nil {
+		return 0, err
+	}
+
+	// Skip late replies to earlier (timed-out) probes until ours arrives.
+	buf := make([]byte, UDPEchoPacketSize)
+	for {
+		n, err := m.conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		if n == UDPEchoPacketSize && bytes.Equal(buf, nonce) {
+			return time.Since(start), nil
+		}
+	}
+}
+
+func (m *udpEchoMeter) Close() error {
+	if m.conn == nil {
+		return nil
+	}
+	return m.conn.Close()
+}
+
+// ServeUDPEcho answers UDP echo probes on addr until ctx is cancelled.
+func ServeUDPEcho(ctx context.Context, addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 512)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if n != UDPEchoPacketSize {
+			continue
+		}
+		_, _ = conn.WriteTo(buf[:n], peer)
+	}
+}
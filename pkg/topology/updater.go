@@ -2,9 +2,11 @@ package topology
 
 import (
 	"context"
+	"log"
 	"math/rand"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -28,18 +30,55 @@ type Store interface {
 type NodeLister func(ctx context.Context) ([]NodeTarget, error)
 type LatencyProbe func(ctx context.Context, from NodeTarget, to NodeTarget) (float64, error)
 
+// BandwidthProbe measures the throughput in Mbps from one node to another.
+type BandwidthProbe func(ctx context.Context, from NodeTarget, to NodeTarget) (float64, error)
+
 type UpdaterConfig struct {
-	Store      Store
+	Store Store
+	// NotFound reports whether a GetNetworkTopology error only means no
+	// topology has been stored yet. Any other read error skips the round,
+	// so a transient failure never overwrites the stored topology.
+	NotFound   func(err error) bool
 	ListNodes  NodeLister
 	Probe      LatencyProbe
 	Mode       ProbeMode
 	Interval   time.Duration
 	SampleSize int
+
+	// LatencyWindow is the number of probe outcomes kept per link and
+	// EWMAAlpha the smoothing factor applied to new samples.
+	LatencyWindow int
+	EWMAAlpha     float64
+
+	// PruneAfter is how long a node may be missing from ListNodes before
+	// its rows and columns are removed from the topology. The grace period
+	// keeps a worker that misses a heartbeat from losing its history.
+	PruneAfter time.Duration
+
+	// FailureThreshold is the number of consecutive lost probes after
+	// which a link counts as down when computing partitions.
+	FailureThreshold int
+
+	// BandwidthProbe is optional. Bulk transfers are expensive, so they run
+	// on their own, slower BandwidthInterval.
+	BandwidthProbe    BandwidthProbe
+	BandwidthInterval time.Duration
 }
 
 type Updater struct {
 	cfg UpdaterConfig
 	rng *rand.Rand
+
+	// mu serializes the latency round and the bandwidth write-back, which
+	// both read, modify and save the stored topology, and guards rng.
+	mu sync.Mutex
+
+	// missingSince records when a node present in the topology was first
+	// absent from ListNodes.
+	missingSince map[string]time.Time
+	// partitions is the component count found by the previous round, used
+	// to log only when the partitioning changes.
+	partitions int
 }
 
 func ParseProbeMode(raw string) ProbeMode {
@@ -61,10 +100,26 @@ func NewUpdater(cfg UpdaterConfig) *Updater {
 	if cfg.SampleSize <= 0 {
 		cfg.SampleSize = 2
 	}
+	if cfg.LatencyWindow <= 0 {
+		cfg.LatencyWindow = DefaultLatencyWindow
+	}
+	if cfg.EWMAAlpha <= 0 || cfg.EWMAAlpha > 1 {
+		cfg.EWMAAlpha = DefaultEWMAAlpha
+	}
+	if cfg.BandwidthInterval <= 0 {
+		cfg.BandwidthInterval = 10 * time.Minute
+	}
+	if cfg.PruneAfter <= 0 {
+		cfg.PruneAfter = 3 * cfg.Interval
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultFailureThreshold
+	}
 
 	return &Updater{
-		cfg: cfg,
-		rng: rand.New(rand.NewSource(time.Now().UnixNano())),
+		cfg:          cfg,
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		missingSince: make(map[string]time.Time),
 	}
 }
 
@@ -79,6 +134,12 @@ func (u *Updater) Run(ctx context.Context) {
 	ticker := time.NewTicker(u.cfg.Interval)
 	defer ticker.Stop()
 
+	// Bulk transfers can take minutes per round, so bandwidth probing runs
+	// on its own and never holds up the latency rounds.
+	if u.cfg.BandwidthProbe != nil {
+		go u.runBandwidth(ctx)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -89,50 +150,206 @@ func (u *Updater) Run(ctx context.Context) {
 	}
 }
 
-func (u *Updater) UpdateOnce(ctx context.Context) error {
+func (u *Updater) runBandwidth(ctx context.Context) {
+	_ = u.UpdateBandwidthOnce(ctx)
+
+	ticker := time.NewTicker(u.cfg.BandwidthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = u.UpdateBandwidthOnce(ctx)
+		}
+	}
+}
+
+// UpdateBandwidthOnce runs a throughput probe for every selected pair of
+// distinct nodes and stores the measured capacity in NodeBandwidth.
+func (u *Updater) UpdateBandwidthOnce(ctx context.Context) error {
+	if u.cfg.BandwidthProbe == nil {
+		return nil
+	}
+
 	nodes, err := u.cfg.ListNodes(ctx)
 	if err != nil {
 		return err
 	}
-	if len(nodes) == 0 {
+	if len(nodes) < 2 {
 		return nil
 	}
 
+	type measurement struct {
+		from, to string
+		mbps     float64
+		at       time.Time
+	}
+	u.mu.Lock()
+	pairs := u.selectProbePairs(nodes, nil)
+	u.mu.Unlock()
+
+	measured := make([]measurement, 0)
+	for _, p := range pairs {
+		if p.from.ID == p.to.ID {
+			continue
+		}
+		mbps, probeErr := u.cfg.BandwidthProbe(ctx, p.from, p.to)
+		if probeErr != nil {
+			continue
+		}
+		measured = append(measured, measurement{from: p.from.ID, to: p.to.ID, mbps: mbps, at: time.Now().UTC()})
+	}
+
+	if len(measured) == 0 {
+		return nil
+	}
+
+	// Re-read the topology after the (slow) transfers so concurrent latency
+	// updates and reservations are not overwritten with a stale snapshot.
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	current, err := u.loadTopology(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range measured {
+		current.SetBandwidth(m.from, m.to, m.mbps, m.at)
+	}
+
+	current.Version++
+	return u.cfg.Store.SaveNetworkTopology(ctx, current)
+}
+
+// loadTopology reads the stored topology, starting from an empty one when
+// none has been stored yet.
+func (u *Updater) loadTopology(ctx context.Context) (*NetworkTopology, error) {
 	current, err := u.cfg.Store.GetNetworkTopology(ctx)
-	if err != nil || current == nil {
+	if err != nil {
+		if u.cfg.NotFound == nil || !u.cfg.NotFound(err) {
+			return nil, err
+		}
+		current = nil
+	}
+	if current == nil {
 		current = &NetworkTopology{}
 	}
+	return current, nil
+}
+
+func (u *Updater) UpdateOnce(ctx context.Context) error {
+	nodes, err := u.cfg.ListNodes(ctx)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	current, err := u.loadTopology(ctx)
+	if err != nil {
+		return err
+	}
 	if current.NodeLatencies == nil {
 		current.NodeLatencies = make(map[string]map[string]float64)
 	}
 
-	pairs := u.selectProbePairs(nodes)
+	pairs := u.selectProbePairs(nodes, current)
 	for _, p := range pairs {
 		if p.from.ID == p.to.ID {
-			u.setLatency(current, p.from.ID, p.to.ID, 0)
+			current.RecordLatencySample(p.from.ID, p.to.ID, 0, u.cfg.LatencyWindow, u.cfg.EWMAAlpha)
 			continue
 		}
 
 		latency, probeErr := u.cfg.Probe(ctx, p.from, p.to)
 		if probeErr != nil {
+			current.RecordLatencyLoss(p.from.ID, p.to.ID, u.cfg.LatencyWindow, u.cfg.EWMAAlpha)
 			continue
 		}
-		u.setLatency(current, p.from.ID, p.to.ID, latency)
+		current.RecordLatencySample(p.from.ID, p.to.ID, latency, u.cfg.LatencyWindow, u.cfg.EWMAAlpha)
+		current.UpdateCoordinates(p.from.ID, p.to.ID, latency)
 	}
 
+	u.pruneDeparted(current, nodes, time.Now())
+	u.markPartitions(current, nodes)
+
 	current.Version++
 	return u.cfg.Store.SaveNetworkTopology(ctx, current)
 }
 
+// pruneDeparted removes nodes that have been missing from the node list for
+// longer than PruneAfter and returns their IDs.
+func (u *Updater) pruneDeparted(nt *NetworkTopology, nodes []NodeTarget, now time.Time) []string {
+	listed := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		listed[n.ID] = true
+		delete(u.missingSince, n.ID)
+	}
+
+	pruned := make([]string, 0)
+	for id := range nt.NodeIDs() {
+		if listed[id] {
+			continue
+		}
+		since, ok := u.missingSince[id]
+		if !ok {
+			u.missingSince[id] = now
+			continue
+		}
+		if now.Sub(since) < u.cfg.PruneAfter {
+			continue
+		}
+		nt.RemoveNode(id)
+		delete(u.missingSince, id)
+		pruned = append(pruned, id)
+	}
+
+	if len(pruned) > 0 {
+		sort.Strings(pruned)
+		log.Printf("Topology: pruned departed node(s) %s", strings.Join(pruned, ", "))
+	}
+	return pruned
+}
+
+// markPartitions recomputes the connected components of the listed nodes
+// and logs when the cluster splits or heals.
+func (u *Updater) markPartitions(nt *NetworkTopology, nodes []NodeTarget) {
+	ids := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	components := nt.MarkPartitions(ids, u.cfg.FailureThreshold)
+
+	if len(components) == u.partitions {
+		return
+	}
+	previous := u.partitions
+	u.partitions = len(components)
+	if len(components) > 1 {
+		groups := make([]string, 0, len(components))
+		for _, members := range components {
+			groups = append(groups, "["+strings.Join(members, ", ")+"]")
+		}
+		log.Printf("Topology: network partitioned into %d components: %s", len(components), strings.Join(groups, " "))
+	} else if previous > 1 {
+		log.Printf("Topology: network partition healed")
+	}
+}
+
 type probePair struct {
 	from NodeTarget
 	to   NodeTarget
 }
 
-func (u *Updater) selectProbePairs(nodes []NodeTarget) []probePair {
+// selectProbePairs returns the pairs to probe this round. In sampled mode
+// the current topology, when given, is used to favour pairs whose latency
+// the coordinate model predicts worst.
+func (u *Updater) selectProbePairs(nodes []NodeTarget, current *NetworkTopology) []probePair {
 	mode := ParseProbeMode(string(u.cfg.Mode))
 	if mode == ProbeModeSampled {
-		return u.selectSampledPairs(nodes, u.cfg.SampleSize)
+		return u.selectSampledPairs(nodes, u.cfg.SampleSize, current)
 	}
 	return u.selectFullMeshPairs(nodes)
 }
@@ -147,7 +364,7 @@ func (u *Updater) selectFullMeshPairs(nodes []NodeTarget) []probePair {
 	return pairs
 }
 
-func (u *Updater) selectSampledPairs(nodes []NodeTarget, sampleSize int) []probePair {
+func (u *Updater) selectSampledPairs(nodes []NodeTarget, sampleSize int, current *NetworkTopology) []probePair {
 	pairs := make([]probePair, 0, len(nodes)*(sampleSize+1))
 	for _, from := range nodes {
 		// always include self latency baseline
@@ -172,19 +389,23 @@ func (u *Updater) selectSampledPairs(nodes []NodeTarget, sampleSize int) []probe
 			limit = len(targets)
 		}
 
+		// Spend half of the budget on the pairs with the highest estimation
+		// error and leave the rest random so well-predicted links are still
+		// re-checked occasionally.
+		if current != nil {
+			priority := (limit + 1) / 2
+			errs := make(map[string]float64, len(targets))
+			for _, t := range targets {
+				errs[t.ID] = current.EstimationError(from.ID, t.ID)
+			}
+			sort.SliceStable(targets, func(i, j int) bool { return errs[targets[i].ID] > errs[targets[j].ID] })
+			rest := targets[priority:]
+			u.rng.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+		}
+
 		for i := 0; i < limit; i++ {
 			pairs = append(pairs, probePair{from: from, to: targets[i]})
 		}
 	}
 	return pairs
 }
-
-func (u *Updater) setLatency(nt *NetworkTopology, fromNodeID, toNodeID string, latency float64) {
-	if nt.NodeLatencies == nil {
-		nt.NodeLatencies = make(map[string]map[string]float64)
-	}
-	if _, ok := nt.NodeLatencies[fromNodeID]; !ok {
-		nt.NodeLatencies[fromNodeID] = make(map[string]float64)
-	}
-	nt.NodeLatencies[fromNodeID][toNodeID] = latency
-}
@@ -0,0 +1,214 @@
+package topology
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Vivaldi tuning, following Dabek et al. "Vivaldi: A Decentralized Network
+// Coordinate System" (SIGCOMM '04) with the height-vector extension.
+const (
+	vivaldiDimensions = 3
+	// vivaldiCe bounds how quickly a node's error estimate moves.
+	vivaldiCe = 0.25
+	// vivaldiCc bounds how far a single sample moves a coordinate.
+	vivaldiCc = 0.25
+	// vivaldiInitialError is the error of a node with no samples yet.
+	vivaldiInitialError = 1.5
+	// vivaldiMinHeight keeps heights positive (milliseconds).
+	vivaldiMinHeight = 0.01
+	// maxTrustedEstimateError is the largest node error for which an
+	// estimate is handed to the scheduler; newer coordinates are too noisy.
+	maxTrustedEstimateError = 0.5
+)
+
+// Coordinate is a node's position in Vivaldi space. The distance between two
+// coordinates approximates the RTT between the nodes in milliseconds; Height
+// models the node's access-link delay, and Error is the node's confidence in
+// its own position (relative error, lower is better).
+type Coordinate struct {
+	Vec    []float64 `json:"vec"`
+	Height float64   `json:"height"`
+	Error  float64   `json:"error"`
+}
+
+func newCoordinate() *Coordinate {
+	return &Coordinate{
+		Vec:    make([]float64, vivaldiDimensions),
+		Height: vivaldiMinHeight,
+		Error:  vivaldiInitialError,
+	}
+}
+
+// DistanceTo returns the estimated RTT in milliseconds between c and other.
+func (c *Coordinate) DistanceTo(other *Coordinate) float64 {
+	return euclidean(c.Vec, other.Vec) + c.Height + other.Height
+}
+
+// update moves c towards or away from remote so that their distance better
+// matches the observed rtt.
+func (c *Coordinate) update(remote *Coordinate, rtt float64) {
+	if rtt <= 0 {
+		return
+	}
+
+	dist := c.DistanceTo(remote)
+	weight := c.Error / (c.Error + remote.Error)
+	if math.IsNaN(weight) {
+		weight = 0.5
+	}
+
+	sampleError := math.Abs(dist-rtt) / rtt
+	c.Error = sampleError*vivaldiCe*weight + c.Error*(1-vivaldiCe*weight)
+	if c.Error > vivaldiInitialError {
+		c.Error = vivaldiInitialError
+	}
+
+	delta := vivaldiCc * weight
+	force := delta * (rtt - dist)
+
+	dir, mag := unitVector(c.Vec, remote.Vec)
+	for i := range c.Vec {
+		c.Vec[i] += dir[i] * force
+	}
+	if mag > 0 {
+		c.Height = (c.Height+remote.Height)*force/mag + c.Height
+	}
+	if c.Height < vivaldiMinHeight {
+		c.Height = vivaldiMinHeight
+	}
+}
+
+func euclidean(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// unitVector returns the unit vector pointing from b to a and the distance
+// between them. Coincident points get a random direction so they can
+// separate.
+func unitVector(a, b []float64) ([]float64, float64) {
+	out := make([]float64, len(a))
+	mag := euclidean(a, b)
+	if mag > 1e-9 {
+		for i := range a {
+			out[i] = (a[i] - b[i]) / mag
+		}
+		return out, mag
+	}
+
+	norm := 0.0
+	for i := range out {
+		out[i] = rand.Float64() - 0.5
+		norm += out[i] * out[i]
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		out[0] = 1
+		return out, 0
+	}
+	for i := range out {
+		out[i] /= norm
+	}
+	return out, 0
+}
+
+// UpdateCoordinates applies one RTT observation between two distinct nodes
+// to both of their coordinates.
+func (nt *NetworkTopology) UpdateCoordinates(nodeA, nodeB string, rttMs float64) {
+	if nt == nil || nodeA == nodeB || rttMs <= 0 {
+		return
+	}
+	a := nt.ensureCoordinate(nodeA)
+	b := nt.ensureCoordinate(nodeB)
+
+	// Update against a snapshot so the second update does not see the
+	// first one's movement.
+	aBefore := &Coordinate{Vec: append([]float64(nil), a.Vec...), Height: a.Height, Error: a.Error}
+	a.update(b, rttMs)
+	b.update(aBefore, rttMs)
+}
+
+// GetCoordinate returns the Vivaldi coordinate for a node.
+func (nt *NetworkTopology) GetCoordinate(nodeID string) (*Coordinate, bool) {
+	if nt == nil || nt.Coordinates == nil {
+		return nil, false
+	}
+	c, ok := nt.Coordinates[nodeID]
+	return c, ok && c != nil
+}
+
+// EstimateLatency returns the coordinate-based RTT estimate between two
+// nodes in milliseconds.
+func (nt *NetworkTopology) EstimateLatency(nodeA, nodeB string) (float64, bool) {
+	if nodeA == nodeB {
+		return 0, true
+	}
+	a, ok := nt.GetCoordinate(nodeA)
+	if !ok {
+		return 0, false
+	}
+	b, ok := nt.GetCoordinate(nodeB)
+	if !ok {
+		return 0, false
+	}
+	return a.DistanceTo(b), true
+}
+
+// GetLatencyOrEstimate returns the measured statistic for a link when one
+// exists and otherwise the Vivaldi estimate, provided both coordinates have
+// settled. estimated reports which of the two was returned.
+func (nt *NetworkTopology) GetLatencyOrEstimate(nodeA, nodeB string, stat LatencyStatistic) (latency float64, estimated bool, ok bool) {
+	if v, ok := nt.GetLatencyStatistic(nodeA, nodeB, stat); ok {
+		return v, false, true
+	}
+	a, okA := nt.GetCoordinate(nodeA)
+	b, okB := nt.GetCoordinate(nodeB)
+	if !okA || !okB || a.Error > maxTrustedEstimateError || b.Error > maxTrustedEstimateError {
+		return 0, false, false
+	}
+	if v, ok := nt.EstimateLatency(nodeA, nodeB); ok {
+		return v, true, true
+	}
+	return 0, false, false
+}
+
+// EstimationError scores how poorly the coordinates are known to describe a
+// link. Measured links use the relative difference between estimate and
+// measurement; unmeasured links use the nodes' own error estimates plus one,
+// so they rank above any measured link the model already predicts well.
+func (nt *NetworkTopology) EstimationError(nodeA, nodeB string) float64 {
+	if nodeA == nodeB {
+		return 0
+	}
+
+	a, okA := nt.GetCoordinate(nodeA)
+	b, okB := nt.GetCoordinate(nodeB)
+	if !okA || !okB {
+		return 1 + 2*vivaldiInitialError
+	}
+
+	if measured, ok := nt.GetLatencyStatistic(nodeA, nodeB, LatencyEWMA); ok && measured > 0 {
+		return math.Abs(a.DistanceTo(b)-measured) / measured
+	}
+	return 1 + a.Error + b.Error
+}
+
+func (nt *NetworkTopology) ensureCoordinate(nodeID string) *Coordinate {
+	if nt.Coordinates == nil {
+		nt.Coordinates = make(map[string]*Coordinate)
+	}
+	c, ok := nt.Coordinates[nodeID]
+	if !ok || c == nil || len(c.Vec) != vivaldiDimensions {
+		c = newCoordinate()
+		nt.Coordinates[nodeID] = c
+	}
+	return c
+}
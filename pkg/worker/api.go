@@ -1,7 +1,10 @@
 package worker
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi"
@@ -11,7 +14,11 @@ type Api struct {
 	Address string
 	Port    int
 	Worker  *Worker
-	Router  *chi.Mux
+	// Peers limits probe targets to registered workers; without it every
+	// probe request is refused.
+	Peers  *PeerSet
+	Router *chi.Mux
+	server *http.Server
 }
 
 func (a *Api) initRouter() {
@@ -25,10 +32,28 @@ func (a *Api) initRouter() {
 	})
 	a.Router.Route("/stats", func(r chi.Router) {
 		r.Get("/", a.GetStatsHandler)
+		r.Get("/history", a.GetStatsHistoryHandler)
+	})
+	a.Router.Route("/probe", func(r chi.Router) {
+		r.Get("/ping", a.PingHandler)
+		r.Get("/latency", a.ProbeLatencyHandler)
+		r.Post("/sink", a.SinkHandler)
+		r.Get("/bandwidth", a.ProbeBandwidthHandler)
 	})
 }
 
 func (a *Api) Start() {
 	a.initRouter()
-	http.ListenAndServe(fmt.Sprintf("%s:%d", a.Address, a.Port), a.Router)
+	a.server = &http.Server{Addr: fmt.Sprintf("%s:%d", a.Address, a.Port), Handler: a.Router}
+	if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Worker API stopped: %v", err)
+	}
+}
+
+// Shutdown gracefully stops the API server started by Start.
+func (a *Api) Shutdown(ctx context.Context) error {
+	if a.server == nil {
+		return nil
+	}
+	return a.server.Shutdown(ctx)
 }
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/store"
+	"github.com/docker/docker/client"
+)
+
+// ParseLabels turns "key=value" pairs (as given to --label) into a label map.
+// Each entry may itself hold several comma-separated pairs.
+func ParseLabels(raw []string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, entry := range raw {
+		for _, pair := range strings.Split(entry, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			key = strings.TrimSpace(key)
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid label %q: expected key=value", pair)
+			}
+			labels[key] = strings.TrimSpace(value)
+		}
+	}
+	return labels, nil
+}
+
+// DescribeHost fills in the architecture, OS, Docker version and total
+// capacity of the local machine on meta.
+func DescribeHost(meta *store.Worker) {
+	meta.Arch = runtime.GOARCH
+	meta.OS = runtime.GOOS
+	meta.DockerVersion = dockerVersion()
+	meta.Capacity = store.WorkerCapacity{
+		Cores:     runtime.NumCPU(),
+		MemoryKb:  GetMemoryInfo().MemTotal,
+		DiskBytes: GetDiskInfo().All,
+	}
+}
+
+func dockerVersion() string {
+	dc, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		log.Printf("Error creating docker client: %v", err)
+		return ""
+	}
+	defer dc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	version, err := dc.ServerVersion(ctx)
+	if err != nil {
+		log.Printf("Error reading docker server version: %v", err)
+		return ""
+	}
+	return version.Version
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/aditip149209/okube/pkg/task"
 	"github.com/go-chi/chi"
@@ -17,6 +18,14 @@ type ErrResponse struct {
 }
 
 func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if a.Worker.IsDraining() {
+		msg := "worker is draining; not accepting new tasks"
+		log.Println(msg)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusServiceUnavailable, Message: msg})
+		return
+	}
+
 	d := json.NewDecoder(r.Body)
 	d.DisallowUnknownFields()
 
@@ -26,7 +35,7 @@ func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		msg := fmt.Sprintf("Error unmarshalling body: %v\n", err)
-		log.Printf(msg)
+		log.Print(msg)
 		w.WriteHeader(400)
 		e := ErrResponse{
 			HTTPStatusCode: 400,
@@ -78,3 +87,22 @@ func (a *Api) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(a.Worker.stats)
 }
+
+// GetStatsHistoryHandler returns the most recent stats samples, oldest first.
+// The optional "limit" query parameter caps the number of samples returned.
+func (a *Api) GetStatsHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			w.WriteHeader(400)
+			json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: 400, Message: fmt.Sprintf("invalid limit %q", raw)})
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(a.Worker.StatsHistory(limit))
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// peerRefreshInterval is the least time between two fetches of the worker
+// list, so unknown targets cannot make the worker flood the manager.
+const peerRefreshInterval = 10 * time.Second
+
+// PeerSet knows the addresses of the workers registered with the manager.
+// Probe handlers only measure towards these, so the worker cannot be used
+// to reach arbitrary hosts.
+type PeerSet struct {
+	client *ManagerClient
+
+	mu        sync.Mutex
+	addrs     map[string]bool
+	fetchedAt time.Time
+}
+
+// NewPeerSet returns a PeerSet that reads the worker list through client.
+func NewPeerSet(client *ManagerClient) *PeerSet {
+	return &PeerSet{client: client, addrs: make(map[string]bool)}
+}
+
+// Allows reports whether target is a registered worker's address. A target
+// not seen before triggers a refresh of the list, at most once per
+// peerRefreshInterval.
+func (p *PeerSet) Allows(ctx context.Context, target string) bool {
+	if p == nil || p.client == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.addrs[target] {
+		return true
+	}
+	if time.Since(p.fetchedAt) < peerRefreshInterval {
+		return false
+	}
+
+	p.fetchedAt = time.Now()
+	addrs, err := p.client.PeerAddresses(ctx)
+	if err != nil {
+		return false
+	}
+	p.addrs = make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		p.addrs[addr] = true
+	}
+	return p.addrs[target]
+}
@@ -0,0 +1,253 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/topology"
+)
+
+const (
+	defaultProbeSamples = 5
+	maxProbeSamples     = 20
+	probeTimeout        = 3 * time.Second
+
+	defaultBandwidthProbeBytes = 8 << 20
+	maxBandwidthProbeBytes     = 256 << 20
+	bandwidthProbeTimeout      = 60 * time.Second
+)
+
+// LatencyProbeResult is the outcome of measuring round-trip time from this
+// worker to a peer worker.
+type LatencyProbeResult struct {
+	Target    string    `json:"target"`
+	Transport string    `json:"transport"`
+	Samples   int       `json:"samples"`
+	MinMs     float64   `json:"minMs"`
+	MedianMs  float64   `json:"medianMs"`
+	RttsMs    []float64 `json:"rttsMs"`
+}
+
+// BandwidthProbeResult is the outcome of a timed bulk transfer from this
+// worker to a peer worker.
+type BandwidthProbeResult struct {
+	Target     string    `json:"target"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"durationMs"`
+	Mbps       float64   `json:"mbps"`
+	MeasuredAt time.Time `json:"measuredAt"`
+}
+
+// PingHandler answers peer latency probes with an empty response so the
+// measured time is dominated by the network round trip.
+func (a *Api) PingHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ProbeLatencyHandler handles
+// GET /probe/latency?target=host:port&samples=N&transport=http|tcp|udp.
+// The manager calls it to learn the RTT from this worker to a peer; targets
+// that are not registered workers are refused.
+func (a *Api) ProbeLatencyHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: 400, Message: "target is required"})
+		return
+	}
+	if !a.Peers.Allows(r.Context(), target) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusForbidden, Message: fmt.Sprintf("target %s is not a registered worker", target)})
+		return
+	}
+
+	samples := defaultProbeSamples
+	if raw := r.URL.Query().Get("samples"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			w.WriteHeader(400)
+			json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: 400, Message: fmt.Sprintf("invalid samples %q", raw)})
+			return
+		}
+		samples = n
+	}
+	if samples > maxProbeSamples {
+		samples = maxProbeSamples
+	}
+
+	transport := topology.ProbeTransportHTTP
+	if raw := r.URL.Query().Get("transport"); raw != "" {
+		transport = topology.ParseProbeTransport(raw, "")
+		if transport == "" {
+			w.WriteHeader(400)
+			json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: 400, Message: fmt.Sprintf("invalid transport %q", raw)})
+			return
+		}
+	}
+	result, err := MeasureLatency(r.Context(), target, samples, transport)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadGateway, Message: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// MeasureLatency measures samples round trips to target over transport and
+// reports min and median RTT in milliseconds. A warm-up round trip is made
+// first so connection setup and ARP resolution are not measured (for TCP
+// every sample is a fresh handshake, which is itself one round trip).
+func MeasureLatency(ctx context.Context, target string, samples int, transport topology.ProbeTransport) (*LatencyProbeResult, error) {
+	if samples <= 0 {
+		samples = defaultProbeSamples
+	}
+
+	meter := topology.NewRTTMeter(transport, target)
+	defer meter.Close()
+
+	measureCtx, cancel := context.WithTimeout(ctx, time.Duration(samples+1)*probeTimeout)
+	defer cancel()
+
+	if _, err := meter.Measure(measureCtx); err != nil {
+		return nil, fmt.Errorf("%s probe to %s failed: %w", transport, target, err)
+	}
+
+	rtts := make([]float64, 0, samples)
+	for i := 0; i < samples; i++ {
+		d, err := meter.Measure(measureCtx)
+		if err != nil {
+			return nil, fmt.Errorf("%s probe to %s failed: %w", transport, target, err)
+		}
+		rtts = append(rtts, float64(d)/float64(time.Millisecond))
+	}
+
+	sorted := append([]float64(nil), rtts...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+
+	return &LatencyProbeResult{
+		Target:    target,
+		Transport: string(transport),
+		Samples:   len(rtts),
+		MinMs:     sorted[0],
+		MedianMs:  median,
+		RttsMs:    rtts,
+	}, nil
+}
+
+// SinkHandler handles POST /probe/sink. It reads and discards the request
+// body so a peer can time how fast it can push data to this worker.
+func (a *Api) SinkHandler(w http.ResponseWriter, r *http.Request) {
+	body := io.LimitReader(r.Body, maxBandwidthProbeBytes)
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: 400, Message: fmt.Sprintf("error reading probe payload: %v", err)})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ProbeBandwidthHandler handles GET /probe/bandwidth?target=host:port&bytes=N.
+// The manager calls it to learn the throughput from this worker to a peer;
+// targets that are not registered workers are refused.
+func (a *Api) ProbeBandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: 400, Message: "target is required"})
+		return
+	}
+	if !a.Peers.Allows(r.Context(), target) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusForbidden, Message: fmt.Sprintf("target %s is not a registered worker", target)})
+		return
+	}
+
+	size := int64(defaultBandwidthProbeBytes)
+	if raw := r.URL.Query().Get("bytes"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 {
+			w.WriteHeader(400)
+			json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: 400, Message: fmt.Sprintf("invalid bytes %q", raw)})
+			return
+		}
+		size = n
+	}
+	if size > maxBandwidthProbeBytes {
+		size = maxBandwidthProbeBytes
+	}
+
+	result, err := MeasureBandwidth(r.Context(), target, size)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusBadGateway, Message: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// zeroReader is an endless source of zero bytes used as probe payload.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// MeasureBandwidth uploads size bytes to target's /probe/sink and reports the
+// achieved throughput in megabits per second.
+func MeasureBandwidth(ctx context.Context, target string, size int64) (*BandwidthProbeResult, error) {
+	if size <= 0 {
+		size = defaultBandwidthProbeBytes
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bandwidthProbeTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("http://%s/probe/sink", target)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, io.LimitReader(zeroReader{}, size))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("bandwidth probe to %s failed with status %d", target, resp.StatusCode)
+	}
+	if elapsed <= 0 {
+		return nil, fmt.Errorf("bandwidth probe to %s completed too quickly to measure", target)
+	}
+
+	return &BandwidthProbeResult{
+		Target:     target,
+		Bytes:      size,
+		DurationMs: float64(elapsed) / float64(time.Millisecond),
+		Mbps:       float64(size) * 8 / elapsed.Seconds() / 1e6,
+		MeasuredAt: time.Now().UTC(),
+	}, nil
+}
@@ -1,83 +1,354 @@
 package worker
 
 import (
-    "bytes"
-    "context"
-    "encoding/json"
-    "fmt"
-    "log"
-    "net/http"
-    "time"
-
-    "github.com/aditip149209/okube/pkg/store"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aditip149209/okube/pkg/store"
 )
 
-func RegisterWithManager(ctx context.Context, managerAddress string, meta store.Worker) error {
-    meta.Heartbeat = time.Now().UTC()
+const maxManagerRedirects = 3
+
+var errWorkerNotRegistered = errors.New("worker not registered with leader")
+
+// ManagerClient talks to a set of manager endpoints on behalf of a worker.
+// It caches the address of the current leader, follows leader redirects
+// (HTTP 307) for every method, and falls back to the remaining endpoints when
+// the cached leader stops answering.
+type ManagerClient struct {
+	Endpoints  []string
+	HTTPClient *http.Client
 
-    payload, err := json.Marshal(meta)
-    if err != nil {
-        return err
-    }
+	mu     sync.Mutex
+	leader string
+}
 
-    req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/workers", managerAddress), bytes.NewBuffer(payload))
-    if err != nil {
-        return err
-    }
-    req.Header.Set("Content-Type", "application/json")
+// NewManagerClient creates a ManagerClient for the given manager endpoints
+// (host:port).
+func NewManagerClient(endpoints []string) *ManagerClient {
+	return &ManagerClient{
+		Endpoints: endpoints,
+		HTTPClient: &http.Client{
+			Timeout: 5 * time.Second,
+			// Redirects are followed manually so the request body is re-sent
+			// and the leader address can be cached.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
 
-    client := &http.Client{Timeout: 5 * time.Second}
-    resp, err := client.Do(req)
-    if err != nil {
-        return err
-    }
-    defer resp.Body.Close()
+// Leader returns the cached leader address, or "" if none is known yet.
+func (c *ManagerClient) Leader() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leader
+}
 
-    if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-        return fmt.Errorf("unexpected status code %d during registration", resp.StatusCode)
-    }
+func (c *ManagerClient) setLeader(addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if addr != c.leader {
+		log.Printf("Manager leader is now %s", addr)
+	}
+	c.leader = addr
+}
 
-    return nil
+// candidates returns the addresses to try in order: the cached leader first,
+// then every configured endpoint.
+func (c *ManagerClient) candidates() []string {
+	leader := c.Leader()
+	out := make([]string, 0, len(c.Endpoints)+1)
+	if leader != "" {
+		out = append(out, leader)
+	}
+	for _, ep := range c.Endpoints {
+		if ep != leader {
+			out = append(out, ep)
+		}
+	}
+	return out
 }
 
-func sendHeartbeat(ctx context.Context, managerAddress, workerID string) error {
-    req, err := http.NewRequestWithContext(ctx, http.MethodPut, fmt.Sprintf("http://%s/workers/%s/heartbeat", managerAddress, workerID), nil)
-    if err != nil {
-        return err
-    }
+// DiscoverLeader queries each endpoint's /status and caches the leader. An
+// endpoint that reports itself as leader wins; otherwise the leader address
+// advertised by a follower is used when it is routable.
+func (c *ManagerClient) DiscoverLeader(ctx context.Context) (string, error) {
+	type statusResponse struct {
+		Role          string `json:"role"`
+		LeaderAddress string `json:"leader_address"`
+	}
+
+	var advertised string
+	var lastErr error
+	for _, ep := range c.Endpoints {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/status", ep), nil)
+		if err != nil {
+			return "", err
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", ep, err)
+			continue
+		}
 
-    client := &http.Client{Timeout: 5 * time.Second}
-    resp, err := client.Do(req)
-    if err != nil {
-        return err
-    }
-    defer resp.Body.Close()
+		var status statusResponse
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("%s: decoding status: %w", ep, err)
+			continue
+		}
 
-    if resp.StatusCode != http.StatusNoContent {
-        return fmt.Errorf("unexpected status code %d during heartbeat", resp.StatusCode)
-    }
+		if status.Role == "leader" {
+			c.setLeader(ep)
+			return ep, nil
+		}
+		if advertised == "" && routableAddress(status.LeaderAddress) {
+			advertised = status.LeaderAddress
+		}
+	}
 
-    return nil
+	if advertised != "" {
+		c.setLeader(advertised)
+		return advertised, nil
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("no manager leader found; last error: %w", lastErr)
+	}
+	return "", errors.New("no manager leader found")
 }
 
-func StartHeartbeat(ctx context.Context, managerAddress, workerID string, interval time.Duration) {
-    if interval <= 0 {
-        interval = 10 * time.Second
-    }
-
-    ticker := time.NewTicker(interval)
-    defer ticker.Stop()
-
-    for {
-        select {
-        case <-ctx.Done():
-            return
-        case <-ticker.C:
-            heartbeatCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-            if err := sendHeartbeat(heartbeatCtx, managerAddress, workerID); err != nil {
-                log.Printf("Error sending heartbeat for worker %s: %v", workerID, err)
-            }
-            cancel()
-        }
-    }
+// routableAddress reports whether addr is a host:port that another machine
+// can dial (i.e. not empty and not a wildcard bind address).
+func routableAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return false
+	}
+	return true
+}
+
+// do sends a request to the cluster, trying the cached leader first and then
+// the other endpoints. Followers that cannot reach a leader answer 503, in
+// which case the next endpoint is tried.
+func (c *ManagerClient) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var lastErr error
+	for _, ep := range c.candidates() {
+		resp, err := c.doWithRedirects(ctx, method, fmt.Sprintf("http://%s%s", ep, path), body, maxManagerRedirects)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", ep, err)
+			continue
+		}
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			msg, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("%s: manager unavailable: %s", ep, bytes.TrimSpace(msg))
+			continue
+		}
+		if c.Leader() == "" {
+			c.setLeader(ep)
+		}
+		return resp, nil
+	}
+
+	if lastErr != nil {
+		return nil, fmt.Errorf("all manager endpoints failed; last error: %w", lastErr)
+	}
+	return nil, errors.New("no manager endpoints configured")
+}
+
+func (c *ManagerClient) doWithRedirects(ctx context.Context, method, target string, body []byte, remaining int) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTemporaryRedirect && remaining > 0 {
+		if loc := resp.Header.Get("Location"); loc != "" {
+			resp.Body.Close()
+			if u, err := url.Parse(loc); err == nil && routableAddress(u.Host) {
+				c.setLeader(u.Host)
+			}
+			return c.doWithRedirects(ctx, method, loc, body, remaining-1)
+		}
+	}
+
+	return resp, nil
+}
+
+// Register registers the worker with the current leader.
+func (c *ManagerClient) Register(ctx context.Context, meta store.Worker) error {
+	meta.Heartbeat = time.Now().UTC()
+
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/workers", payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d during registration", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Heartbeat sends a single heartbeat for workerID.
+func (c *ManagerClient) Heartbeat(ctx context.Context, workerID string) error {
+	resp, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/workers/%s/heartbeat", workerID), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errWorkerNotRegistered
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status code %d during heartbeat", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// NotifyDraining tells the manager that this worker is shutting down so it
+// stops scheduling onto it and reschedules the worker's tasks elsewhere.
+func (c *ManagerClient) NotifyDraining(ctx context.Context, workerID string) error {
+	resp, err := c.do(ctx, http.MethodPost, fmt.Sprintf("/workers/%s/drain", workerID), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status code %d during drain notification", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Deregister removes this worker's registration from the manager.
+func (c *ManagerClient) Deregister(ctx context.Context, workerID string) error {
+	resp, err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/workers/%s", workerID), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("unexpected status code %d during deregistration", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// PeerAddresses returns the addresses of every worker registered with the
+// manager.
+func (c *ManagerClient) PeerAddresses(ctx context.Context) ([]string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/nodes", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d listing workers", resp.StatusCode)
+	}
+
+	var workers []store.Worker
+	if err := json.NewDecoder(resp.Body).Decode(&workers); err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(workers))
+	for _, w := range workers {
+		addrs = append(addrs, w.Address)
+	}
+	return addrs, nil
+}
+
+// StartHeartbeat sends heartbeats for meta.ID every interval until ctx is
+// cancelled. When a heartbeat fails the leader is rediscovered, and the
+// worker re-registers whenever the leader changes, reports that it does not
+// know this worker, or draining reports a change. Re-registration replaces
+// the manager's record, so it carries the worker's current draining state.
+func StartHeartbeat(ctx context.Context, c *ManagerClient, meta store.Worker, interval time.Duration, draining func() bool) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastLeader := c.Leader()
+	lastDraining := meta.Draining
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			heartbeatCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			err := c.Heartbeat(heartbeatCtx, meta.ID)
+			cancel()
+			if err != nil && !errors.Is(err, errWorkerNotRegistered) {
+				log.Printf("Error sending heartbeat for worker %s: %v", meta.ID, err)
+				// The failed heartbeat may have used up its deadline, so
+				// rediscovery gets a fresh one.
+				discoverCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				if _, discoverErr := c.DiscoverLeader(discoverCtx); discoverErr != nil {
+					log.Printf("Error rediscovering manager leader: %v", discoverErr)
+				}
+				cancel()
+			}
+
+			leader := c.Leader()
+			if draining != nil {
+				meta.Draining = draining()
+			}
+			if errors.Is(err, errWorkerNotRegistered) || (leader != "" && leader != lastLeader) || meta.Draining != lastDraining {
+				log.Printf("Re-registering worker %s with manager leader %s", meta.ID, leader)
+				registerCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				if regErr := c.Register(registerCtx, meta); regErr != nil {
+					log.Printf("Error re-registering worker %s: %v", meta.ID, regErr)
+				} else {
+					lastLeader = leader
+					lastDraining = meta.Draining
+				}
+				cancel()
+			}
+		}
+	}
 }
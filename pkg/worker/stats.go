@@ -2,16 +2,49 @@ package worker
 
 import (
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/c9s/goprocinfo/linux"
 )
 
+// defaultStatsHistorySize is the number of samples kept for /stats/history.
+// With the 5 second collection interval this covers the last ten minutes.
+const defaultStatsHistorySize = 120
+
+// diskSectorSize is the unit /proc/diskstats reports sector counts in.
+const diskSectorSize = 512
+
 type Stats struct {
 	MemStats  *linux.MemInfo
 	DiskStats *linux.Disk
 	CpuStats  *linux.CPUStat
 	LoadStats *linux.LoadAvg
 	Taskcount int
+
+	// Rates below are derived from the delta between this sample and the
+	// previous one, so they describe current load rather than the average
+	// since boot. They are zero for the very first sample.
+	Timestamp  time.Time
+	CpuPercent float64
+	PerCoreCpu []float64
+	Network    []InterfaceRate
+	DiskIO     []DiskIORate
+}
+
+// InterfaceRate is the throughput of a network interface in bytes/second.
+type InterfaceRate struct {
+	Iface         string  `json:"iface"`
+	RxBytesPerSec float64 `json:"rxBytesPerSec"`
+	TxBytesPerSec float64 `json:"txBytesPerSec"`
+}
+
+// DiskIORate is the throughput of a block device in bytes/second.
+type DiskIORate struct {
+	Device           string  `json:"device"`
+	ReadBytesPerSec  float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
 }
 
 func (s *Stats) MemTotalKb() uint64 {
@@ -26,8 +59,12 @@ func (s *Stats) MemUsedKb() uint64 {
 	return s.MemStats.MemTotal - s.MemStats.MemAvailable
 }
 
-func (s *Stats) MemUsedPercent() uint64 {
-	return s.MemStats.MemAvailable / s.MemStats.MemTotal
+// MemUsedPercent returns used memory as a percentage (0-100) of total memory.
+func (s *Stats) MemUsedPercent() float64 {
+	if s.MemStats == nil || s.MemStats.MemTotal == 0 {
+		return 0
+	}
+	return float64(s.MemUsedKb()) / float64(s.MemStats.MemTotal) * 100
 }
 
 func (s *Stats) DiskTotal() uint64 {
@@ -42,25 +79,225 @@ func (s *Stats) DiskUsed() uint64 {
 	return s.DiskStats.Used
 }
 
+// CpuUsage returns the fraction (0-1) of CPU time spent busy across all cores
+// during the last sampling interval.
 func (s *Stats) CpuUsage() float64 {
-	idle := s.CpuStats.Idle + s.CpuStats.IOWait
-	nonIdle := s.CpuStats.User + s.CpuStats.Nice + s.CpuStats.System + s.CpuStats.IRQ + s.CpuStats.SoftIRQ + s.CpuStats.Steal
-	total := idle + nonIdle
-
-	if total == 0 {
-		return 0.00
-	}
-
-	return (float64(total) - float64(idle)) / float64(total)
+	return s.CpuPercent
 }
 
+// GetStats takes a single snapshot of host counters without any rates.
 func GetStats() *Stats {
 	return &Stats{
 		MemStats:  GetMemoryInfo(),
 		DiskStats: GetDiskInfo(),
 		CpuStats:  GetCpuStats(),
 		LoadStats: GetLoadAvg(),
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// statsSampler turns cumulative /proc counters into per-interval rates by
+// remembering the previous raw readings.
+type statsSampler struct {
+	prevTime time.Time
+	prevCPU  *linux.Stat
+	prevNet  map[string]linux.NetworkStat
+	prevDisk map[string]linux.DiskStat
+}
+
+// Sample reads the current counters and returns a Stats value whose rate
+// fields are computed against the previous call.
+func (s *statsSampler) Sample() *Stats {
+	now := time.Now().UTC()
+	stats := &Stats{
+		MemStats:  GetMemoryInfo(),
+		DiskStats: GetDiskInfo(),
+		LoadStats: GetLoadAvg(),
+		Timestamp: now,
+	}
+
+	cpu, err := linux.ReadStat("/proc/stat")
+	if err != nil {
+		log.Printf("Error reading from /proc/stat")
+		cpu = &linux.Stat{}
+	}
+	stats.CpuStats = &cpu.CPUStatAll
+
+	netStats, err := linux.ReadNetworkStat("/proc/net/dev")
+	if err != nil {
+		log.Printf("Error reading from /proc/net/dev")
+	}
+	diskStats, err := linux.ReadDiskStats("/proc/diskstats")
+	if err != nil {
+		log.Printf("Error reading from /proc/diskstats")
+	}
+
+	elapsed := now.Sub(s.prevTime).Seconds()
+	if s.prevCPU != nil && elapsed > 0 {
+		stats.CpuPercent = cpuBusyFraction(s.prevCPU.CPUStatAll, cpu.CPUStatAll)
+		stats.PerCoreCpu = make([]float64, 0, len(cpu.CPUStats))
+		for i, core := range cpu.CPUStats {
+			if i >= len(s.prevCPU.CPUStats) {
+				break
+			}
+			stats.PerCoreCpu = append(stats.PerCoreCpu, cpuBusyFraction(s.prevCPU.CPUStats[i], core))
+		}
+
+		for _, n := range netStats {
+			if n.Iface == "lo" {
+				continue
+			}
+			prev, ok := s.prevNet[n.Iface]
+			if !ok {
+				continue
+			}
+			stats.Network = append(stats.Network, InterfaceRate{
+				Iface:         n.Iface,
+				RxBytesPerSec: counterRate(prev.RxBytes, n.RxBytes, elapsed),
+				TxBytesPerSec: counterRate(prev.TxBytes, n.TxBytes, elapsed),
+			})
+		}
+
+		for _, d := range diskStats {
+			if strings.HasPrefix(d.Name, "loop") || strings.HasPrefix(d.Name, "ram") {
+				continue
+			}
+			prev, ok := s.prevDisk[d.Name]
+			if !ok {
+				continue
+			}
+			stats.DiskIO = append(stats.DiskIO, DiskIORate{
+				Device:           d.Name,
+				ReadBytesPerSec:  counterRate(prev.ReadSectors, d.ReadSectors, elapsed) * diskSectorSize,
+				WriteBytesPerSec: counterRate(prev.WriteSectors, d.WriteSectors, elapsed) * diskSectorSize,
+			})
+		}
+	}
+
+	s.prevTime = now
+	s.prevCPU = cpu
+	s.prevNet = make(map[string]linux.NetworkStat, len(netStats))
+	for _, n := range netStats {
+		s.prevNet[n.Iface] = n
+	}
+	s.prevDisk = make(map[string]linux.DiskStat, len(diskStats))
+	for _, d := range diskStats {
+		s.prevDisk[d.Name] = d
+	}
+
+	return stats
+}
+
+// cpuBusyFraction returns the share of non-idle time between two cumulative
+// CPU readings.
+func cpuBusyFraction(prev, cur linux.CPUStat) float64 {
+	prevIdle := prev.Idle + prev.IOWait
+	curIdle := cur.Idle + cur.IOWait
+	prevTotal := prevIdle + prev.User + prev.Nice + prev.System + prev.IRQ + prev.SoftIRQ + prev.Steal
+	curTotal := curIdle + cur.User + cur.Nice + cur.System + cur.IRQ + cur.SoftIRQ + cur.Steal
+
+	if curTotal <= prevTotal || curIdle < prevIdle {
+		return 0
+	}
+
+	total := float64(curTotal - prevTotal)
+	idle := float64(curIdle - prevIdle)
+	return (total - idle) / total
+}
+
+// counterRate returns the per-second increase of a monotonically increasing
+// counter, treating wrap-around or resets as zero.
+func counterRate(prev, cur uint64, seconds float64) float64 {
+	if cur < prev || seconds <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
+
+// StatsSample is the compact form of a Stats reading kept in the history.
+type StatsSample struct {
+	Timestamp      time.Time       `json:"timestamp"`
+	CpuPercent     float64         `json:"cpuPercent"`
+	PerCoreCpu     []float64       `json:"perCoreCpu,omitempty"`
+	MemUsedPercent float64         `json:"memUsedPercent"`
+	Load1          float64         `json:"load1"`
+	TaskCount      int             `json:"taskCount"`
+	Network        []InterfaceRate `json:"network,omitempty"`
+	DiskIO         []DiskIORate    `json:"diskIO,omitempty"`
+}
+
+// Sample returns the compact history form of s.
+func (s *Stats) Sample() StatsSample {
+	sample := StatsSample{
+		Timestamp:      s.Timestamp,
+		CpuPercent:     s.CpuPercent,
+		PerCoreCpu:     s.PerCoreCpu,
+		MemUsedPercent: s.MemUsedPercent(),
+		TaskCount:      s.Taskcount,
+		Network:        s.Network,
+		DiskIO:         s.DiskIO,
+	}
+	if s.LoadStats != nil {
+		sample.Load1 = s.LoadStats.Last1Min
+	}
+	return sample
+}
+
+// StatsHistory is a fixed-size ring buffer of recent samples.
+type StatsHistory struct {
+	mu      sync.RWMutex
+	samples []StatsSample
+	next    int
+	full    bool
+}
+
+// NewStatsHistory creates a history holding at most size samples.
+func NewStatsHistory(size int) *StatsHistory {
+	if size <= 0 {
+		size = defaultStatsHistorySize
+	}
+	return &StatsHistory{samples: make([]StatsSample, size)}
+}
+
+// Add records a sample, overwriting the oldest one when the buffer is full.
+func (h *StatsHistory) Add(sample StatsSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Samples returns up to limit of the most recent samples, oldest first. A
+// non-positive limit returns the whole history.
+func (h *StatsHistory) Samples(limit int) []StatsSample {
+	if h == nil {
+		return []StatsSample{}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := h.next
+	if h.full {
+		count = len(h.samples)
+	}
+	if limit <= 0 || limit > count {
+		limit = count
+	}
+
+	out := make([]StatsSample, 0, limit)
+	for i := count - limit; i < count; i++ {
+		idx := i
+		if h.full {
+			idx = (h.next + i) % len(h.samples)
+		}
+		out = append(out, h.samples[idx])
 	}
+	return out
 }
 
 func GetMemoryInfo() *linux.MemInfo {
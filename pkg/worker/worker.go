@@ -1,9 +1,12 @@
 package worker
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aditip149209/okube/pkg/task"
@@ -17,17 +20,43 @@ type Worker struct {
 	Db        map[uuid.UUID]*task.Task
 	TaskCount int
 	stats     *Stats
+	history   *StatsHistory
+
+	// mu serialises task execution with draining so that a container is
+	// never started after Drain has begun stopping them. draining is read
+	// without it, so new work is refused at once while Drain runs.
+	mu       sync.Mutex
+	draining atomic.Bool
+}
+
+// New returns a worker named name with an empty queue, task database and
+// stats history.
+func New(name string) *Worker {
+	return &Worker{
+		Name:    name,
+		Queue:   *queue.New(),
+		Db:      make(map[uuid.UUID]*task.Task),
+		history: NewStatsHistory(defaultStatsHistorySize),
+	}
 }
 
 func (w *Worker) CollectStats() {
+	sampler := &statsSampler{}
 	for {
 		log.Println("Collecting stats")
-		w.stats = GetStats()
-		w.stats.Taskcount = w.TaskCount
+		stats := sampler.Sample()
+		stats.Taskcount = w.TaskCount
+		w.stats = stats
+		w.history.Add(stats.Sample())
 		time.Sleep(5 * time.Second)
 	}
 }
 
+// StatsHistory returns up to limit of the most recent stats samples.
+func (w *Worker) StatsHistory(limit int) []StatsSample {
+	return w.history.Samples(limit)
+}
+
 func (w *Worker) GetTasks() []*task.Task {
 	tasks := make([]*task.Task, 0, len(w.Db))
 
@@ -124,8 +153,19 @@ func (w *Worker) AddTask(t task.Task) {
 
 func (w *Worker) RunTasks() {
 	for {
+		if w.IsDraining() {
+			log.Println("Worker is draining; no longer processing queued tasks")
+			return
+		}
 		if w.Queue.Len() != 0 {
+			w.mu.Lock()
+			// Drain may have started while this waited for the lock.
+			if w.IsDraining() {
+				w.mu.Unlock()
+				continue
+			}
 			result := w.runTask()
+			w.mu.Unlock()
 			if result.Error != nil {
 				log.Printf("Error running task: %v\n", result.Error)
 			}
@@ -144,6 +184,9 @@ func (w *Worker) InspectTask(t task.Task) task.DockerInspectResponse {
 }
 
 func (w *Worker) updateTasks() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	for id, t := range w.Db {
 		if t.State == task.Running {
 			resp := w.InspectTask(*t)
@@ -176,3 +219,57 @@ func (w *Worker) UpdateTasks() {
 		time.Sleep(15 * time.Second)
 	}
 }
+
+// IsDraining reports whether Drain has been called on this worker.
+func (w *Worker) IsDraining() bool {
+	return w.draining.Load()
+}
+
+// Drain stops accepting new work and stops every running container, giving
+// each one its task's grace period. It returns early with ctx's error if the
+// drain deadline expires before all containers are stopped.
+func (w *Worker) Drain(ctx context.Context) error {
+	w.draining.Store(true)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for id, t := range w.Db {
+		if t.State != task.Running && t.State != task.Scheduled {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("drain interrupted before stopping task %s: %w", id, err)
+		}
+
+		stopped := make(chan task.DockerResult, 1)
+		go func(t task.Task) {
+			stopped <- w.stopContainer(t)
+		}(*t)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("drain timed out while stopping task %s: %w", id, ctx.Err())
+		case result := <-stopped:
+			if result.Error != nil {
+				log.Printf("Error stopping task %s during drain: %v\n", id, result.Error)
+			}
+		}
+
+		t.EndTime = time.Now().UTC()
+		t.State = task.Completed
+	}
+
+	return nil
+}
+
+// stopContainer stops the container backing t without touching w.Db.
+func (w *Worker) stopContainer(t task.Task) task.DockerResult {
+	if t.ContainerID == "" {
+		return task.DockerResult{Action: "stop", Result: "no container"}
+	}
+	config := task.NewConfig(&t)
+	d := task.NewDocker(config)
+	return d.Stop(t.ContainerID)
+}